@@ -0,0 +1,62 @@
+package fester
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// SummaryRecord returns a single NDJSON line summarizing the manifest: a
+// "summary" record with the hostname, date, and the fields of the summary.
+func (o *OutputMap) SummaryRecord() ([]byte, error) {
+	record := map[string]interface{}{}
+	if o.Summary != nil {
+		b, err := json.Marshal(o.Summary)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(b, &record); err != nil {
+			return nil, err
+		}
+	}
+	record["type"] = "summary"
+	record["hostname"] = o.Hostname
+	record["date"] = o.Date
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// AppendOutput appends content to the file at path, creating it and its
+// parent directories if needed, with FileMode and DirMode. The file is locked
+// while it's written, so concurrent appends from several processes don't
+// interleave.
+func AppendOutput(path string, content []byte) error {
+	if err := mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, FileMode)
+	if err == nil {
+		err = f.Chmod(FileMode)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	} else if os.IsExist(err) {
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	}
+	if err != nil {
+		return err
+	}
+	if err = lockFile(f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
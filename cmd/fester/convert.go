@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/johnworth/fester"
+)
+
+// convertMain runs the convert subcommand with args, the arguments after
+// "convert", and returns the exit code.
+func convertMain(args []string) int {
+	convertFlags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	convertFormat := convertFlags.String("format", "json", "The format to convert to, one of: "+strings.Join(fester.Formats, ", "))
+	convertOutput := convertFlags.String("output", "", "The file to write the converted manifest to. Defaults to stdout.")
+	convertIndent := convertFlags.Int("indent", 2, "The number of spaces to indent JSON with; 0 writes it compactly and less than 0 indents with tabs")
+	convertCompact := convertFlags.Bool("compact", false, "Write JSON without indentation, like -indent 0")
+	convertFlags.Usage = func() {
+		fmt.Fprintln(convertFlags.Output(), "Usage: fester convert [-format json|yaml|ndjson] [-indent n] [-output file] [<manifest>|-]")
+		convertFlags.PrintDefaults()
+	}
+	if err := convertFlags.Parse(args); err != nil {
+		return 2
+	}
+	if err := convert(convertFlags.Args(), *convertFormat, *convertOutput, jsonIndent(*convertIndent, *convertCompact, true)); err != nil {
+		slog.Error("fester convert failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// convert reads the manifest named in args, or stdin if there is none, and
+// writes it in format to the output file, or stdout if it's empty.
+func convert(args []string, format, output string, indent int) error {
+	if len(args) > 1 {
+		return errors.New("convert takes the path of at most one manifest")
+	}
+	if !fester.ValidFormat(format) {
+		return fmt.Errorf("-format must be one of: %s", strings.Join(fester.Formats, ", "))
+	}
+	filename := "-"
+	if len(args) == 1 {
+		filename = args[0]
+	}
+	manifest, err := fester.ReadManifest(filename)
+	if err != nil {
+		return fmt.Errorf("reading manifest: %s", err)
+	}
+	content, err := manifest.Marshal(format, indent)
+	if err != nil {
+		return fmt.Errorf("marshalling manifest: %s", err)
+	}
+	return fester.WriteOutput(output, content)
+}
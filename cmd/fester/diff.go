@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/johnworth/fester"
+)
+
+// diffMain runs the diff subcommand with args, the arguments after "diff",
+// and returns the exit code: 0 if the manifests are the same, 1 if they
+// differ, and 2 if they couldn't be compared.
+func diffMain(args []string) int {
+	diffFlags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	diffFormat := diffFlags.String("format", "text", "The output format, text or json")
+	diffIgnore := diffFlags.String("ignore", "", "A comma-separated list of fields whose changes don't count, e.g. date,container.State,image.Size")
+	diffFlags.Usage = func() {
+		fmt.Fprintln(diffFlags.Output(), "Usage: fester diff [-format text|json] [-ignore fields] <old-manifest> <new-manifest>")
+		diffFlags.PrintDefaults()
+	}
+	if err := diffFlags.Parse(args); err != nil {
+		return 2
+	}
+	d, err := diff(diffFlags.Args(), *diffFormat, splitList(*diffIgnore))
+	if err != nil {
+		slog.Error("fester diff failed", "error", err)
+		return 2
+	}
+	if !d.Empty() {
+		return 1
+	}
+	return 0
+}
+
+// diff compares the two manifests named in args, leaving out changes to the
+// ignored fields, and writes the differences to stdout in the given format.
+func diff(args []string, format string, ignoreFields []string) (*fester.Diff, error) {
+	if len(args) != 2 {
+		return nil, errors.New("diff takes the paths of the old and new manifests")
+	}
+	if format != "text" && format != "json" {
+		return nil, errors.New("-format must be text or json")
+	}
+	ignore, err := fester.ParseIgnore(ignoreFields)
+	if err != nil {
+		return nil, err
+	}
+	old, err := fester.ReadManifest(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("reading old manifest: %s", err)
+	}
+	cur, err := fester.ReadManifest(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("reading new manifest: %s", err)
+	}
+	d := fester.CompareIgnoring(old, cur, ignore)
+	return d, writeDiff(d, format)
+}
+
+// writeDiff writes d to stdout in the given format, text or json.
+func writeDiff(d *fester.Diff, format string) error {
+	if format == "json" {
+		content, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshalling diff: %s", err)
+		}
+		_, err = os.Stdout.Write(append(content, '\n'))
+		return err
+	}
+	_, err := os.Stdout.WriteString(d.String())
+	return err
+}
+
+// drift collects a manifest from hosts and compares it with the reference
+// manifest at path, leaving out changes to the -ignore fields. The differences
+// are written to stdout, and an error is returned if there are any.
+func drift(ctx context.Context, path string, hosts []fester.Host, opts fester.Options) error {
+	ignore, err := fester.ParseIgnore(splitList(*ignoreFields))
+	if err != nil {
+		return fmt.Errorf("--ignore: %s", err)
+	}
+	reference, err := fester.ReadManifest(path)
+	if err != nil {
+		return fmt.Errorf("reading reference manifest: %s", err)
+	}
+	output, err := collect(ctx, hosts, opts)
+	if err != nil {
+		return err
+	}
+	if len(output.Errors) > 0 {
+		return fmt.Errorf("couldn't collect the whole manifest: %s", strings.Join(output.Errors, "; "))
+	}
+	d := fester.CompareIgnoring(reference, output, ignore)
+	if err = writeDiff(d, "text"); err != nil {
+		return err
+	}
+	if !d.Empty() {
+		return fmt.Errorf("the host has drifted from %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/johnworth/fester"
+)
+
+// report writes what a -dry-run collected, and what would have been done with
+// the manifest, to w. content is the manifest as it would have been written to
+// path.
+func report(w io.Writer, output *fester.OutputMap, content []byte, path string, pulls []string) {
+	s := output.Summary
+	fmt.Fprintf(w, "dry run: found %d images, %d containers (%d running), %d volumes, %d networks, and %d files\n",
+		s.ImageCount, s.ContainerCount, s.RunningContainerCount, len(output.Volumes), len(output.Networks), len(output.Files))
+	for _, image := range pulls {
+		fmt.Fprintf(w, "would pull %s\n", image)
+	}
+	dest := "stdout"
+	if path != "" {
+		dest = path
+	}
+	if *appendOut {
+		fmt.Fprintf(w, "would append a summary record to %s\n", dest)
+	} else if (*postURL == "" && *s3Bucket == "" && *splitDir == "" && *pushAddr == "") || path != "" {
+		fmt.Fprintf(w, "would write %d bytes to %s\n", len(content), dest)
+	}
+	if *dedupDir != "" {
+		fmt.Fprintf(w, "would store the manifest in %s, or write a pointer to it if it's there already\n", fester.ExpandPath(*dedupDir, output))
+	}
+	if *splitDir != "" {
+		fmt.Fprintf(w, "would write the manifest's sections to %s\n", fester.ExpandPath(*splitDir, output))
+	}
+	if *checksumFile {
+		if path == "" {
+			fmt.Fprintln(w, "would write the checksum to stderr")
+		} else {
+			fmt.Fprintf(w, "would write the checksum to %s.sha256\n", path)
+		}
+	}
+	if signer != nil {
+		sigPath := fester.ExpandPath(*signOutput, output)
+		if sigPath == "" {
+			sigPath = path + ".sig"
+		}
+		fmt.Fprintf(w, "would write the signature to %s\n", sigPath)
+	}
+	if *postURL != "" {
+		fmt.Fprintf(w, "would POST %d bytes to %s\n", len(content), *postURL)
+	}
+	if *pushAddr != "" {
+		fmt.Fprintf(w, "would push %d bytes to %s\n", len(content), *pushAddr)
+	}
+	if *s3Bucket != "" {
+		fmt.Fprintf(w, "would upload %d bytes to s3://%s/%s\n", len(content), *s3Bucket, fester.ExpandKey(*s3Key, output))
+	}
+}
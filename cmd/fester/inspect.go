@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/johnworth/fester"
+)
+
+// inspectMain runs the inspect subcommand with args, the arguments after
+// "inspect", and returns the exit code.
+func inspectMain(args []string) int {
+	inspectFlags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	wide := inspectFlags.Bool("wide", false, "Also list each image and container")
+	inspectFlags.Usage = func() {
+		fmt.Fprintln(inspectFlags.Output(), "Usage: fester inspect [-wide] <manifest>|-")
+		inspectFlags.PrintDefaults()
+	}
+	if err := inspectFlags.Parse(args); err != nil {
+		return 2
+	}
+	if inspectFlags.NArg() != 1 {
+		slog.Error("fester inspect failed", "error", errors.New("inspect takes the path of a manifest, or - for stdin"))
+		return 2
+	}
+	output, err := fester.ReadManifest(inspectFlags.Arg(0))
+	if err != nil {
+		slog.Error("fester inspect failed", "error", err)
+		return 1
+	}
+	if err = describe(os.Stdout, output, *wide); err != nil {
+		slog.Error("fester inspect failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// describe writes a summary of the manifest to w as a table. With wide, each
+// image and container is listed too.
+func describe(w io.Writer, output *fester.OutputMap, wide bool) error {
+	containers := output.AllContainers()
+	var running int
+	for _, c := range containers {
+		if c.State == "running" {
+			running++
+		}
+	}
+	var size int64
+	for _, i := range output.Images {
+		size += i.Size
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Host:\t%s\n", output.Hostname)
+	fmt.Fprintf(tw, "Date:\t%s\n", output.Date)
+	fmt.Fprintf(tw, "Images:\t%d (%s)\n", len(output.Images), humanSize(size))
+	fmt.Fprintf(tw, "Containers:\t%d (%d running, %d stopped)\n", len(containers), running, len(containers)-running)
+	if wide {
+		fmt.Fprintln(tw, "\nIMAGE ID\tTAGS\tSIZE")
+		for _, i := range output.Images {
+			tags := strings.Join(i.RepoTags, ", ")
+			if tags == "" {
+				tags = "<none>"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", shortID(i.ID), tags, humanSize(i.Size))
+		}
+		fmt.Fprintln(tw, "\nCONTAINER ID\tNAME\tIMAGE\tSTATE")
+		for _, c := range containers {
+			names := strings.TrimPrefix(strings.Join(c.Names, ", "), "/")
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", shortID(c.ID), names, c.Image, c.State)
+		}
+	}
+	return tw.Flush()
+}
+
+// shortID returns the first 12 characters of id, without its sha256: prefix,
+// as docker shows IDs.
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// humanSize formats a number of bytes in decimal units, as docker does.
+func humanSize(n int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB", "PB"}
+	f := float64(n)
+	u := 0
+	for f >= 1000 && u < len(units)-1 {
+		f /= 1000
+		u++
+	}
+	if u == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.3g%s", f, units[u])
+}
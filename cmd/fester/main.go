@@ -0,0 +1,891 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/johnworth/fester"
+)
+
+var (
+	reg               = flag.String("registry", "", "The registry to pull from")
+	imgs              = flag.String("images", "", "Path to a new-line delimited list of image names")
+	tag               = flag.String("tag", "", "The tag to pull")
+	outf              = flag.String("output", "", "The file to write the manifest to. Defaults to stdout.")
+	hostname          = flag.String("hostname", "", "The hostname to record in the manifest; defaults to the FQDN of the host, or its hostname if that can't be resolved")
+	splitDir          = flag.String("split-dir", "", "A directory to also write each section of the manifest to as its own file, such as images.json")
+	dedupDir          = flag.String("dedup-dir", "", "A directory to store each distinct manifest in once; a manifest already stored, apart from its date, is written out as a small pointer to it instead")
+	fileMode          = flag.String("file-mode", "0600", "The permissions, in octal, of the files fester writes")
+	dirMode           = flag.String("dir-mode", "0755", "The permissions, in octal, of the directories fester creates")
+	appendOut         = flag.Bool("append", false, "With -format ndjson, append one summary record to the -output file instead of overwriting it")
+	files             = flag.String("files", "", "A comma-separated list of files, or glob patterns matching them, that need to be included in the manifest.")
+	embedFiles        = flag.Bool("embed-files", false, "Embed the base64-encoded contents of the -files in the manifest")
+	embedMaxSize      = flag.Int64("embed-max-size", 64*1024, "With -embed-files, the size in bytes of the biggest file to embed; bigger files are marked as truncated")
+	profile           = flag.String("profile", "", "A preset of flag defaults: minimal, full, or audit; flags given on the command line override it")
+	format            = flag.String("format", "json", "The output format, one of: "+strings.Join(fester.Formats, ", "))
+	tmplText          = flag.String("template", "", "A Go text/template to format the manifest with instead of -format")
+	tmplFile          = flag.String("template-file", "", "Path to a Go text/template to format the manifest with instead of -format")
+	tee               = flag.Bool("tee", false, "With -output, also write the manifest to stdout")
+	checksumFile      = flag.Bool("checksum-file", false, "Write the SHA-256 checksum of the manifest to the -output file with .sha256 appended, or to stderr when writing to stdout")
+	indent            = flag.Int("indent", 2, "The number of spaces to indent JSON with; 0 writes it compactly and less than 0 indents with tabs")
+	pretty            = flag.Bool("pretty", true, "Indent JSON; -pretty=false is the same as -compact")
+	compact           = flag.Bool("compact", false, "Write JSON without indentation, like -indent 0")
+	gz                = flag.Bool("gzip", false, "Compress the output with gzip, adding .gz to the output file name if needed")
+	tlsCert           = flag.String("tls-cert", dockerCertFile("cert.pem"), "Path to the client certificate used to connect to the Docker daemon")
+	tlsVerify         = flag.Bool("tls-verify", true, "Verify the Docker daemon's TLS certificate; -tls-verify=false is the same as -tls-insecure-skip-verify")
+	tlsInsecure       = flag.Bool("tls-insecure-skip-verify", false, "Connect to the Docker daemon over TLS without verifying its certificate. Only for testing, since the connection can be intercepted")
+	tlsKey            = flag.String("tls-key", dockerCertFile("key.pem"), "Path to the client key used to connect to the Docker daemon")
+	tlsCA             = flag.String("tls-ca", dockerCertFile("ca.pem"), "Path to the CA certificate used to verify the Docker daemon")
+	apiVer            = flag.String("docker-api-version", envOr("DOCKER_API_VERSION", "auto"), "The Docker API version to use, or auto to negotiate it with the daemon. Defaults to $DOCKER_API_VERSION")
+	maxConcurrency    = flag.Int("max-concurrency", fester.DefaultMaxConcurrency, "The most per-container or per-image Docker calls to make at once")
+	strict            = flag.Bool("strict", false, "Fail if any Docker call fails, rather than leaving out the optional sections the daemon refuses")
+	retries           = flag.Int("retries", 3, "The number of times to retry a Docker call that fails with a transient error")
+	retryInterval     = flag.Duration("retry-interval", 2*time.Second, "How long to wait before the first retry; the wait doubles after each attempt")
+	watch             = flag.Bool("watch", false, "Keep running and write a new manifest whenever containers or images are created or removed")
+	since             = flag.Duration("since", 0, "With -watch, apply each event to the last manifest, looking up only the container or image it is about, and collect everything again at least this often; zero collects everything on every event")
+	watchDebounce     = flag.Duration("watch-debounce", 2*time.Second, "With -watch, how long to wait after an event for others before writing a new manifest")
+	interval          = flag.Duration("interval", 0, "When set, keep running and write a new manifest this often")
+	filterFile        = flag.String("filter-file", "", "A JSON or YAML file of image_filters, container_filters, exclude_images, exclude_containers, and promote_labels rules; the matching flags add to them")
+	imageFilter       = flag.String("image-filter", "", "A comma-separated list of reference patterns, e.g. registry.example.com/*; only images with a matching tag are listed")
+	imageRegistry     = flag.String("image-registry", "", "A comma-separated list of registry hosts, e.g. docker.io,quay.io; only images from one of them are listed")
+	imageBefore       = flag.String("image-created-before", "", "Only list images created before this RFC3339 date, or this long ago, e.g. 720h")
+	imageAfter        = flag.String("image-created-after", "", "Only list images created after this RFC3339 date, or this long ago, e.g. 720h")
+	danglingOnly      = flag.Bool("dangling-only", false, "Only list dangling images, those with no tags")
+	rawTags           = flag.Bool("raw-tags", false, "Keep image tags and digests exactly as docker reports them, <none> placeholders and duplicates included")
+	noDangling        = flag.Bool("no-dangling", false, "Leave dangling images, those with no tags, out of the listing")
+	ctrStatus         = flag.String("container-status", "", "A comma-separated list of states, e.g. running,paused; only containers in one of them are listed")
+	groupByCompose    = flag.Bool("group-by-compose", false, "List containers under projects, keyed by Docker Compose project, instead of under containers")
+	runningOnly       = flag.Bool("running-only", false, "Only list running containers and the images they were started from")
+	listImages        = flag.Bool("list-images", true, "List the images on the host; with -list-images=false they are left out of the manifest altogether")
+	listContainers    = flag.Bool("list-containers", true, "List the containers on the host; with -list-containers=false they are left out of the manifest altogether")
+	noSystemInfo      = flag.Bool("no-system-info", false, "Leave out info about the Docker daemon and its host")
+	inspectCtrs       = flag.Bool("inspect-containers", false, "Inspect each container to include its environment, command, entrypoint, and mounts")
+	restartThreshold  = flag.Int("restart-threshold", 0, "With --inspect-containers, list the containers restarted more than this many times in the summary")
+	redactEnv         = flag.String("redact-env", "", "A comma-separated list of substrings, e.g. PASSWORD,TOKEN; environment variables whose names contain one have their values replaced with ***")
+	imageHistory      = flag.Bool("image-history", false, "Include the build history of each image")
+	historyTimeout    = flag.Duration("image-history-timeout", 30*time.Second, "How long to wait for the history of each image; zero or less means no timeout")
+	stats             = flag.Bool("stats", false, "Include a sample of the CPU and memory each running container is using")
+	statsTimeout      = flag.Duration("stats-timeout", 5*time.Second, "How long to wait for the stats of each container; zero or less means no timeout")
+	containerLogs     = flag.Int("container-logs", 0, "Include the last N lines each container logged; zero means none")
+	logsMaxBytes      = flag.Int64("logs-max-bytes", 1<<20, "The most bytes of container logs to include across all containers; zero or less means no limit")
+	diskUsage         = flag.Bool("disk-usage", false, "Include the disk space used by images, containers, volumes, and the build cache")
+	failUnhealthy     = flag.Bool("fail-on-unhealthy", false, "Fail if any container's health check is failing; requires -inspect-containers")
+	maxExited         = flag.Int("max-exited", -1, "Fail if there are more than this many exited containers; negative means no limit")
+	minRunning        = flag.Int("min-running", -1, "Fail if there are fewer than this many running containers; negative means no limit")
+	maxImages         = flag.Int("max-images", -1, "Fail if there are more than this many images; negative means no limit")
+	reqOCILabels      = flag.Bool("require-oci-labels", false, "Fail if any image that isn't dangling lacks the OCI revision and created labels")
+	reqDigests        = flag.Bool("require-digests", false, "Fail if any tagged image has no repo digests")
+	postURL           = flag.String("post-url", "", "When set, POST the manifest to this URL")
+	pushAddr          = flag.String("push-addr", "", "When set, push each manifest as a length-prefixed JSON frame over one long-lived TCP connection to the collector at this host:port")
+	pushAttempts      = flag.Int("push-attempts", 5, "How many times to try pushing a manifest to -push-addr before giving up on it; 0 means no limit")
+	pushTimeout       = flag.Duration("push-timeout", time.Minute, "How long pushing a manifest to -push-addr may take, reconnecting included, before giving up on it; 0 means no limit")
+	pushTLS           = flag.Bool("push-tls", false, "Connect to -push-addr with TLS")
+	s3Bucket          = flag.String("s3-bucket", "", "When set, upload the manifest to this S3 bucket")
+	s3Key             = flag.String("s3-key", "fester/{hostname}.json", "The key to upload the manifest to; {hostname} and {date} are replaced with the manifest's hostname and date")
+	s3Endpoint        = flag.String("s3-endpoint", "", "The endpoint of S3-compatible storage to upload to instead of AWS")
+	listen            = flag.String("listen", "", "When set, serve the manifest over HTTP at this address, e.g. :8080")
+	showVersion       = flag.Bool("version", false, "Print the version of fester and exit")
+	logLevel          = flag.String("log-level", "info", "The minimum level of messages to log: debug, info, warn, or error")
+	quiet             = flag.Bool("quiet", false, "Only log errors, and don't show the progress of pulls; overrides -log-level")
+	logJSON           = flag.Bool("log-json", false, "Log in JSON rather than as text")
+	failFast          = flag.Bool("fail-fast", false, "With more than one -docker-uri, fail as soon as any of the hosts fails")
+	plugins           = flag.Bool("plugins", false, "Include the plugins installed on the Docker daemon")
+	swarm             = flag.Bool("swarm", false, "Include Swarm services and tasks when the daemon is a Swarm manager")
+	sortObjects       = flag.Bool("sort", true, "Sort images and containers so that manifests of an unchanged host are identical")
+	dateFormat        = flag.String("date-format", time.RFC3339, "The Go time layout to format the manifest's date with, or epoch for a Unix timestamp")
+	utc               = flag.Bool("utc", false, "Record the manifest's date in UTC rather than local time")
+	signKeyFile       = flag.String("sign-key", "", "Path to an ASCII-armored OpenPGP private key to sign the manifest with. A passphrase may be given in $FESTER_SIGN_PASSPHRASE")
+	signOutput        = flag.String("sign-output", "", "The file to write the signature to. Defaults to the -output file with .sig appended")
+	compareTo         = flag.String("compare-to", "", "Collect a manifest, print how it differs from this reference manifest, and fail if it does, rather than writing it out")
+	ignoreFields      = flag.String("ignore", "", "With -compare-to, a comma-separated list of fields whose changes don't count, e.g. date,container.State,image.Size")
+	redactCommon      = flag.Bool("redact-common", false, "Redact common credential and token formats, such as AWS access keys, GitHub tokens, and JWTs, wherever they appear in the manifest")
+	dryRun            = flag.Bool("dry-run", false, "Collect a manifest without pulling images, then report what would have been written where instead of writing it")
+	validate          = flag.Bool("validate", false, "Check the manifest against its JSON Schema before writing it")
+	timeoutSystemInfo = flag.Duration("timeout-system-info", 0, "How long getting the system info may take before it is left out; zero or less means no limit of its own")
+	timeoutDiskUsage  = flag.Duration("timeout-disk-usage", 0, "How long getting the disk usage may take before it is left out; zero or less means no limit of its own")
+	timeoutPlugins    = flag.Duration("timeout-plugins", 0, "How long listing the plugins may take before they are left out; zero or less means no limit of its own")
+	timeoutSwarm      = flag.Duration("timeout-swarm", 0, "How long listing the Swarm services and tasks may take before they are left out; zero or less means no limit of its own")
+	timeoutStats      = flag.Duration("timeout-stats", 0, "How long fetching the stats of all the containers may take before the rest are left out; zero or less means no limit of its own")
+	timeoutLogs       = flag.Duration("timeout-container-logs", 0, "How long fetching the logs of all the containers may take before the rest are left out; zero or less means no limit of its own")
+	timeoutHistory    = flag.Duration("timeout-image-history", 0, "How long fetching the history of all the images may take before the rest are left out; zero or less means no limit of its own")
+	shutdownTimeout   = flag.Duration("shutdown-timeout", 10*time.Second, "How long to wait for a manifest write or HTTP request in progress to finish after SIGINT or SIGTERM")
+	timeout           = flag.Duration("timeout", 0, "How long all of the Docker calls for a manifest may take together; zero or less means no timeout")
+)
+
+// tmpl is the parsed -template or -template-file, if either was given.
+var tmpl *fester.Template
+
+// redactions are the compiled -redact-pattern values, along with the common
+// patterns if -redact-common was given.
+var redactions []*regexp.Regexp
+
+// signer signs the manifest if -sign-key was given.
+var signer *fester.Signer
+
+// pusher pushes each manifest to -push-addr if it was given.
+var pusher *fester.Pusher
+
+var (
+	postHeaders       stringList
+	containerLabels   stringList
+	uris              stringList
+	excludeImages     stringList
+	excludeContainers stringList
+	labels            stringList
+	redactPatterns    stringList
+	promoteLabels     stringList
+)
+
+// postHeader holds the parsed -post-header values.
+var postHeader = http.Header{}
+
+func init() {
+	flag.Var(&uris, "docker-uri", "A Docker daemon to connect to, e.g. tcp://docker.example.com:2376. May be repeated to aggregate several hosts into one manifest. Defaults to $DOCKER_HOST")
+	flag.Var(&promoteLabels, "promote-label", "A container label key to copy into the manifest's labels from the containers that carry it, e.g. com.example.role. May be repeated")
+	flag.Var(&labels, "label", "A key=value label to record in the manifest, e.g. env=prod. May be repeated")
+	flag.Var(&excludeImages, "exclude-image", "Leave out images with a tag matching this glob pattern, e.g. monitoring/*. May be repeated")
+	flag.Var(&excludeContainers, "exclude-container", "Leave out containers with a name matching this glob pattern, e.g. *-sidecar. May be repeated")
+	flag.Var(&containerLabels, "container-label", "Only list containers with this label, as key or key=value. May be repeated; all of them must match")
+	flag.Var(&redactPatterns, "redact-pattern", "A regular expression; whatever matches it in any string in the manifest is replaced with ***. May be repeated")
+	flag.Var(&postHeaders, "post-header", "A header to send with -post-url, as \"Name: value\". May be repeated")
+}
+
+// envOr returns the value of the environment variable key, or def if it's
+// empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// dockerCertFile returns the path to the named file in $DOCKER_CERT_PATH, or
+// ~/.docker if that is unset, when $DOCKER_TLS_VERIFY is set. Otherwise it
+// returns "", the same as the docker command.
+func dockerCertFile(name string) string {
+	if os.Getenv("DOCKER_TLS_VERIFY") == "" {
+		return ""
+	}
+	dir := os.Getenv("DOCKER_CERT_PATH")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".docker")
+	}
+	return filepath.Join(dir, name)
+}
+
+// flagSet returns true if the flag with the given name was given on the
+// command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// stringList is a flag.Value that collects each use of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+// Set appends value to the list.
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func main() {
+	flag.Parse()
+	if *showVersion || flag.Arg(0) == "version" {
+		fmt.Printf("fester %s\ngit commit: %s\nbuild date: %s\n", fester.Version, fester.GitCommit, fester.BuildDate)
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		slog.Error("--log-level must be one of: debug, info, warn, error")
+		os.Exit(1)
+	}
+	if *quiet {
+		level = slog.LevelError
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	if *logJSON {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, handlerOpts)))
+	} else {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, handlerOpts)))
+	}
+	if flag.Arg(0) == "diff" {
+		os.Exit(diffMain(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "schema" {
+		os.Stdout.Write(fester.Schema)
+		return
+	}
+	if flag.Arg(0) == "convert" {
+		os.Exit(convertMain(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "inspect" {
+		os.Exit(inspectMain(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "resolve" {
+		os.Exit(resolveMain(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "verify" {
+		os.Exit(verifyMain(flag.Args()[1:]))
+	}
+	if err := run(); err != nil {
+		slog.Error("fester failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run does whatever the command line asks for, returning once it's done or
+// when it's stopped by a signal in the long-running modes.
+func run() error {
+	if *profile != "" {
+		if err := applyProfile(flag.CommandLine, *profile); err != nil {
+			return err
+		}
+	}
+	if *imgs != "" && *reg == "" {
+		return errors.New("--registry must be set with --images")
+	}
+	if *imgs != "" && *tag == "" {
+		return errors.New("--tag must be set with --images")
+	}
+	if !fester.ValidFormat(*format) {
+		return fmt.Errorf("--format must be one of: %s", strings.Join(fester.Formats, ", "))
+	}
+	if *failUnhealthy && !*inspectCtrs {
+		return errors.New("--fail-on-unhealthy requires --inspect-containers")
+	}
+	if *embedFiles && *embedMaxSize <= 0 {
+		return errors.New("--embed-max-size must be more than zero")
+	}
+	if *splitDir != "" && (*format == "ndjson" || *tmplText != "" || *tmplFile != "") {
+		return errors.New("--split-dir only works with --format json or yaml")
+	}
+	var err error
+	if fester.FileMode, err = parseMode("file-mode", *fileMode); err != nil {
+		return err
+	}
+	if fester.DirMode, err = parseMode("dir-mode", *dirMode); err != nil {
+		return err
+	}
+	if *dedupDir != "" && (*format == "ndjson" || *tmplText != "" || *tmplFile != "" || *appendOut) {
+		return errors.New("--dedup-dir only works with --format json or yaml, without --append")
+	}
+	if *appendOut && (*format != "ndjson" || *outf == "") {
+		return errors.New("--append requires --format ndjson and --output")
+	}
+	if *appendOut && *gz {
+		return errors.New("--append and --gzip can't be used together")
+	}
+	if *tee && *outf == "" {
+		return errors.New("--tee requires --output")
+	}
+	if *since > 0 && !*watch {
+		return errors.New("--since requires --watch")
+	}
+	if !*listImages && !*listContainers {
+		return errors.New("--list-images and --list-containers can't both be false")
+	}
+	if *runningOnly && !*listContainers {
+		return errors.New("--running-only requires --list-containers")
+	}
+	if *groupByCompose && !*listContainers {
+		return errors.New("--group-by-compose requires --list-containers")
+	}
+	if *reqOCILabels && !*listImages {
+		return errors.New("--require-oci-labels requires --list-images")
+	}
+	if *runningOnly && *ctrStatus != "" {
+		return errors.New("--running-only and --container-status can't be used together")
+	}
+	if *danglingOnly && *noDangling {
+		return errors.New("--dangling-only and --no-dangling can't be used together")
+	}
+	if *tmplText != "" && *tmplFile != "" {
+		return errors.New("--template and --template-file can't be used together")
+	}
+	if *tmplFile != "" {
+		text, err := ioutil.ReadFile(*tmplFile)
+		if err != nil {
+			return fmt.Errorf("reading template: %s", err)
+		}
+		*tmplText = string(text)
+	}
+	if *tmplText != "" {
+		var err error
+		if tmpl, err = fester.ParseTemplate(*tmplText); err != nil {
+			return fmt.Errorf("parsing template: %s", err)
+		}
+	}
+	patterns := []string(redactPatterns)
+	if *redactCommon {
+		patterns = append(patterns, fester.CommonRedactPatterns...)
+	}
+	compiled, err := fester.CompileRedactPatterns(patterns)
+	if err != nil {
+		return fmt.Errorf("--redact-pattern: %s", err)
+	}
+	redactions = compiled
+	if *signKeyFile != "" {
+		if *outf == "" && *signOutput == "" {
+			return errors.New("--sign-output must be set with --sign-key when writing to stdout")
+		}
+		var err error
+		if signer, err = fester.NewSigner(*signKeyFile, os.Getenv("FESTER_SIGN_PASSPHRASE")); err != nil {
+			return fmt.Errorf("reading signing key: %s", err)
+		}
+	}
+	if *pushTLS && *pushAddr == "" {
+		return errors.New("--push-tls requires --push-addr")
+	}
+	if *pushAddr != "" {
+		if *format != "json" || *tmplText != "" || *gz {
+			return errors.New("--push-addr requires JSON output, without --template or --gzip")
+		}
+		var tlsConfig *tls.Config
+		if *pushTLS {
+			tlsConfig = &tls.Config{}
+		}
+		pusher = fester.NewPusher(*pushAddr, tlsConfig)
+		pusher.MaxAttempts, pusher.Timeout = *pushAttempts, *pushTimeout
+		defer pusher.Close()
+	}
+	for _, h := range postHeaders {
+		name, value, err := fester.ParseHeader(h)
+		if err != nil {
+			return fmt.Errorf("parsing --post-header: %s", err)
+		}
+		postHeader.Add(name, value)
+	}
+	manifestLabels := make(map[string]string)
+	for _, l := range labels {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("--label %q must be in the form key=value", l)
+		}
+		manifestLabels[kv[0]] = kv[1]
+	}
+	var tlsArgs []string
+	if *tlsInsecure || !*tlsVerify {
+		if flagSet("tls-ca") {
+			return errors.New("--tls-ca can't be used when skipping TLS verification")
+		}
+		slog.Warn("NOT VERIFYING THE DOCKER DAEMON'S TLS CERTIFICATE: the connection can be intercepted, so only do this for testing")
+		tlsArgs, err = fester.InsecureTLSArgs(*tlsCert, *tlsKey)
+	} else {
+		tlsArgs, err = fester.TLSArgs(*tlsCert, *tlsKey, *tlsCA)
+	}
+	if err != nil {
+		return fmt.Errorf("configuring TLS: %s", err)
+	}
+	var images []string
+	if *imgs != "" {
+		images, err = fester.ReadImages(*imgs)
+		if err != nil {
+			return fmt.Errorf("reading images: %s", err)
+		}
+	}
+	if len(uris) == 0 {
+		uris = stringList{os.Getenv("DOCKER_HOST")}
+	}
+	// Each host's client is created when it's first used and shared by every
+	// collection after that, whether for -interval, -watch, or -listen. It's
+	// only created again if it fails to reach the daemon.
+	var hosts []fester.Host
+	for _, uri := range uris {
+		cli := fester.NewReconnecting(func() fester.Docker {
+			cli := fester.NewClient(uri, *apiVer, tlsArgs)
+			if *quiet {
+				cli.Progress = ioutil.Discard
+			}
+			return cli
+		})
+		defer cli.Close()
+		hosts = append(hosts, fester.Host{URI: uri, Client: cli})
+	}
+	imageFilters := fester.Filters{}
+	if refs := splitList(*imageFilter); len(refs) > 0 {
+		imageFilters["reference"] = refs
+	}
+	if *danglingOnly {
+		imageFilters["dangling"] = []string{"true"}
+	}
+	if *noDangling {
+		imageFilters["dangling"] = []string{"false"}
+	}
+	containerFilters := fester.Filters{}
+	for _, state := range splitList(*ctrStatus) {
+		if !fester.ValidContainerState(state) {
+			return fmt.Errorf("--container-status must be one of: %s", strings.Join(fester.ContainerStates, ", "))
+		}
+		containerFilters["status"] = append(containerFilters["status"], state)
+	}
+	if len(containerLabels) > 0 {
+		containerFilters["label"] = containerLabels
+	}
+	if *filterFile != "" {
+		rules, err := fester.ReadFilterFile(*filterFile)
+		if err != nil {
+			return fmt.Errorf("reading filter file: %s", err)
+		}
+		// The flags add to the file's rules, except that --dangling-only and
+		// --no-dangling replace its dangling filter.
+		imageFilters = fester.MergeFilters(rules.ImageFilters, imageFilters, "dangling")
+		containerFilters = fester.MergeFilters(rules.ContainerFilters, containerFilters)
+		excludeImages = append(rules.ExcludeImages, excludeImages...)
+		excludeContainers = append(rules.ExcludeContainers, excludeContainers...)
+		promoteLabels = append(rules.PromoteLabels, promoteLabels...)
+	}
+	now := time.Now()
+	var createdBefore, createdAfter time.Time
+	if *imageBefore != "" {
+		if createdBefore, err = fester.ParseTime(*imageBefore, now); err != nil {
+			return fmt.Errorf("--image-created-before: %s", err)
+		}
+	}
+	if *imageAfter != "" {
+		if createdAfter, err = fester.ParseTime(*imageAfter, now); err != nil {
+			return fmt.Errorf("--image-created-after: %s", err)
+		}
+	}
+	if !createdBefore.IsZero() && !createdAfter.IsZero() && !createdAfter.Before(createdBefore) {
+		return errors.New("--image-created-after must be earlier than --image-created-before")
+	}
+	var embedMax int64
+	if *embedFiles {
+		embedMax = *embedMaxSize
+	}
+	opts := fester.Options{
+		Registry:           *reg,
+		Tag:                *tag,
+		Images:             images,
+		ImageFilters:       imageFilters,
+		RawTags:            *rawTags,
+		ImageRegistries:    splitList(*imageRegistry),
+		ImageCreatedBefore: createdBefore,
+		ImageCreatedAfter:  createdAfter,
+		ContainerFilters:   containerFilters,
+		RunningOnly:        *runningOnly,
+		ExcludeImages:      excludeImages,
+		ExcludeContainers:  excludeContainers,
+		SkipSystemInfo:     *noSystemInfo,
+		SkipImages:         !*listImages,
+		SkipContainers:     !*listContainers,
+		DiskUsage:          *diskUsage,
+		Stats:              *stats,
+		StatsTimeout:       *statsTimeout,
+		SectionTimeouts: map[string]time.Duration{
+			fester.SectionSystemInfo:    *timeoutSystemInfo,
+			fester.SectionDiskUsage:     *timeoutDiskUsage,
+			fester.SectionPlugins:       *timeoutPlugins,
+			fester.SectionSwarm:         *timeoutSwarm,
+			fester.SectionStats:         *timeoutStats,
+			fester.SectionContainerLogs: *timeoutLogs,
+			fester.SectionImageHistory:  *timeoutHistory,
+		},
+		ContainerLogs:     *containerLogs,
+		LogsMaxBytes:      *logsMaxBytes,
+		InspectContainers: *inspectCtrs,
+		RedactEnv:         splitList(*redactEnv),
+		RestartThreshold:  *restartThreshold,
+		Files:             splitList(*files),
+		EmbedMaxSize:      embedMax,
+		Plugins:           *plugins,
+		Hostname:          *hostname,
+		Labels:            manifestLabels,
+		PromoteLabels:     promoteLabels,
+		Swarm:             *swarm,
+		Sort:              *sortObjects,
+		DateFormat:        *dateFormat,
+		UTC:               *utc,
+		MaxConcurrency:    *maxConcurrency,
+		Strict:            *strict,
+		Retries:           *retries,
+		RetryInterval:     *retryInterval,
+	}
+	if !*quiet {
+		opts.Progress = newProgressReporter(os.Stderr).report
+	}
+	if *compareTo != "" {
+		return drift(context.Background(), *compareTo, hosts, opts)
+	}
+	if *dryRun {
+		return dryRunSnapshot(context.Background(), hosts, opts)
+	}
+	if *interval <= 0 && *listen == "" && !*watch {
+		return snapshot(context.Background(), hosts, opts)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Collections and writes use work rather than ctx, so one that is in
+	// progress when a signal arrives can finish. It's cancelled if that takes
+	// longer than the -shutdown-timeout.
+	work, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	go func() {
+		<-ctx.Done()
+		// Restore the default handling of the signals, so a second one
+		// exits straight away.
+		stop()
+		time.AfterFunc(*shutdownTimeout, cancelWork)
+	}()
+	errs := make(chan error, 1)
+	served := make(chan struct{})
+	if *listen != "" {
+		go func() {
+			defer close(served)
+			if err := serve(ctx, *listen, hosts, opts); err != nil {
+				errs <- fmt.Errorf("serving HTTP: %s", err)
+			}
+		}()
+	} else {
+		close(served)
+	}
+	shutdown := func() error {
+		slog.Info("shutting down")
+		<-served
+		return nil
+	}
+	if *interval <= 0 && !*watch {
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return shutdown()
+		}
+	}
+	var tick <-chan time.Time
+	if *interval > 0 {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var events <-chan hostEvent
+	if *watch {
+		events = watchEvents(ctx, hosts)
+	}
+	// debounced fires once the -watch-debounce window after the first event
+	// since the last manifest has passed, so a burst of events leads to a
+	// single write.
+	var debounced <-chan time.Time
+	// last is the manifest written last, collected in full at collected, and
+	// pending are the events since. With -since they're applied to last
+	// rather than collecting everything again, unless rescan is set.
+	var (
+		last      *fester.OutputMap
+		collected time.Time
+		pending   []hostEvent
+	)
+	write, rescan := true, true
+	for {
+		if write {
+			if *since > 0 && !rescan && last != nil && time.Since(collected) < *since && applyEvents(work, last, pending, opts) {
+				err = publish(work, last)
+			} else {
+				start := time.Now()
+				if last, err = collect(work, hosts, opts); err == nil {
+					collected = start
+					err = publish(work, last)
+				}
+			}
+			if err != nil && work.Err() == nil {
+				slog.Error("writing manifest failed", "error", err)
+			}
+			pending, rescan = nil, false
+		}
+		write = true
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return shutdown()
+		case <-tick:
+			debounced = nil
+			rescan = true
+		case e := <-events:
+			if *since > 0 {
+				pending = append(pending, e)
+			}
+			if debounced == nil {
+				debounced = time.After(*watchDebounce)
+			}
+			write = false
+		case <-debounced:
+			debounced = nil
+		}
+	}
+}
+
+// collect collects a manifest from hosts, giving up after the -timeout. The
+// result is recorded in the metrics served at /metrics. The manifest is
+// redacted here, rather than when it's written, so that every use of it,
+// whether written, served, pushed, or compared, is redacted.
+func collect(ctx context.Context, hosts []fester.Host, opts fester.Options) (*fester.OutputMap, error) {
+	start := time.Now()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	output, err := fester.CollectAll(ctx, hosts, opts, *failFast)
+	recordCollection(start, output, err)
+	if err == nil && *groupByCompose {
+		output.GroupByCompose()
+	}
+	if err == nil {
+		output.Redact(redactions)
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out after %s talking to Docker", *timeout)
+	}
+	return output, err
+}
+
+// encode formats the manifest with the -template if there is one, or in the
+// -format otherwise. With -validate the manifest is checked against its schema
+// first.
+func encode(output *fester.OutputMap) ([]byte, error) {
+	if *validate {
+		if err := fester.ValidateManifest(output); err != nil {
+			return nil, fmt.Errorf("the manifest doesn't match its schema: %s", err)
+		}
+	}
+	if tmpl != nil {
+		content, err := output.Execute(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("executing template: %s", err)
+		}
+		return content, nil
+	}
+	content, err := output.Marshal(*format, jsonIndent(*indent, *compact, *pretty))
+	if err != nil {
+		return nil, fmt.Errorf("marshalling output: %s", err)
+	}
+	return content, nil
+}
+
+// parseMode parses value, the octal permissions given to the named flag.
+func parseMode(name, value string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || n > 0777 {
+		return 0, fmt.Errorf("--%s must be octal permissions such as 0600, not %q", name, value)
+	}
+	return os.FileMode(n), nil
+}
+
+// jsonIndent returns the indentation to marshal JSON with: indent, unless
+// -compact or -pretty=false ask for none.
+func jsonIndent(indent int, compact, pretty bool) int {
+	if compact || !pretty {
+		return 0
+	}
+	return indent
+}
+
+// contentType returns the Content-Type of the manifest as encoded by encode.
+func contentType() string {
+	if tmpl != nil {
+		return "text/plain; charset=utf-8"
+	}
+	return contentTypes[*format]
+}
+
+// prepare encodes the manifest, replaces it with a pointer if -dedup-dir has
+// it stored already, and compresses it if -gzip was given. It returns the
+// content to write out and the path to write it to.
+func prepare(output *fester.OutputMap) ([]byte, string, error) {
+	content, err := encode(output)
+	if err != nil {
+		return nil, "", err
+	}
+	if *dedupDir != "" && !*dryRun {
+		if content, err = output.Dedup(fester.ExpandPath(*dedupDir, output), *format, jsonIndent(*indent, *compact, *pretty), content); err != nil {
+			return nil, "", fmt.Errorf("deduplicating manifest: %s", err)
+		}
+	}
+	path := fester.ExpandPath(*outf, output)
+	if *gz {
+		if path != "" && !strings.HasSuffix(path, ".gz") {
+			path += ".gz"
+		}
+		content, err = fester.Gzip(content)
+		if err != nil {
+			return nil, "", fmt.Errorf("compressing output: %s", err)
+		}
+	}
+	return content, path, nil
+}
+
+// writeChecksum writes the checksum of content, the manifest as written to
+// path, next to it in a file that sha256sum -c can check. If path is empty the
+// checksum is written to stderr instead.
+func writeChecksum(content []byte, path string) error {
+	if path == "" {
+		_, err := os.Stderr.Write(fester.Checksum(content, "-"))
+		return err
+	}
+	return fester.WriteOutput(path+".sha256", fester.Checksum(content, filepath.Base(path)))
+}
+
+// dryRunSnapshot collects a manifest without pulling any images and reports
+// what snapshot would have done with it, without doing any of it.
+func dryRunSnapshot(ctx context.Context, hosts []fester.Host, opts fester.Options) error {
+	var pulls []string
+	for _, image := range opts.Images {
+		pulls = append(pulls, fester.New(opts.Registry, image, opts.Tag).String())
+	}
+	opts.Images = nil
+	output, err := collect(ctx, hosts, opts)
+	if err != nil {
+		return err
+	}
+	content, path, err := prepare(output)
+	if err != nil {
+		return err
+	}
+	report(os.Stdout, output, content, path, pulls)
+	return check(output)
+}
+
+// snapshot collects a manifest and writes it out as directed by the output
+// flags.
+func snapshot(ctx context.Context, hosts []fester.Host, opts fester.Options) error {
+	output, err := collect(ctx, hosts, opts)
+	if err != nil {
+		return err
+	}
+	return publish(ctx, output)
+}
+
+// publish writes out the manifest as directed by the output flags.
+func publish(ctx context.Context, output *fester.OutputMap) error {
+	content, path, err := prepare(output)
+	if err != nil {
+		return err
+	}
+	if *appendOut {
+		record, err := output.SummaryRecord()
+		if err != nil {
+			return fmt.Errorf("marshalling summary record: %s", err)
+		}
+		if err = fester.AppendOutput(path, record); err != nil {
+			return fmt.Errorf("appending to output file: %s", err)
+		}
+	} else if (*postURL == "" && *s3Bucket == "" && *splitDir == "" && pusher == nil) || path != "" {
+		var teeTo io.Writer
+		if *tee {
+			teeTo = os.Stdout
+		}
+		if err = fester.TeeOutput(path, content, teeTo); err != nil {
+			return fmt.Errorf("writing output file: %s", err)
+		}
+	}
+	if *splitDir != "" {
+		if err = output.WriteSplit(fester.ExpandPath(*splitDir, output), *format, jsonIndent(*indent, *compact, *pretty)); err != nil {
+			return fmt.Errorf("writing split manifest: %s", err)
+		}
+	}
+	if *checksumFile {
+		if err = writeChecksum(content, path); err != nil {
+			return fmt.Errorf("writing checksum: %s", err)
+		}
+	}
+	if signer != nil {
+		sig, err := signer.Sign(content)
+		if err != nil {
+			return fmt.Errorf("signing manifest: %s", err)
+		}
+		sigPath := fester.ExpandPath(*signOutput, output)
+		if sigPath == "" {
+			sigPath = path + ".sig"
+		}
+		if err = fester.WriteOutput(sigPath, sig); err != nil {
+			return fmt.Errorf("writing signature: %s", err)
+		}
+	}
+	if *postURL != "" {
+		header := postHeader.Clone()
+		header.Set("Content-Type", contentType())
+		if *gz {
+			header.Set("Content-Encoding", "gzip")
+		}
+		if err = fester.Post(ctx, *postURL, content, header); err != nil {
+			return fmt.Errorf("posting manifest: %s", err)
+		}
+	}
+	if pusher != nil {
+		if err = pusher.Push(ctx, content); err != nil {
+			return fmt.Errorf("pushing manifest: %s", err)
+		}
+	}
+	if *s3Bucket != "" {
+		key := fester.ExpandKey(*s3Key, output)
+		if err = fester.UploadS3(ctx, *s3Endpoint, *s3Bucket, key, content, contentType(), *gz); err != nil {
+			return fmt.Errorf("uploading manifest to s3://%s/%s: %s", *s3Bucket, key, err)
+		}
+	}
+	return check(output)
+}
+
+// check returns an error if the manifest is incomplete or fails any of the
+// checks requested on the command line.
+func check(output *fester.OutputMap) error {
+	if len(output.Errors) > 0 {
+		return fmt.Errorf("the manifest is incomplete: %s", strings.Join(output.Errors, "; "))
+	}
+	if *reqDigests {
+		if ids := fester.MissingDigests(output.Images); len(ids) > 0 {
+			return fmt.Errorf("images without repo digests: %s", strings.Join(ids, ", "))
+		}
+	}
+	if *reqOCILabels {
+		if ids := fester.MissingOCILabels(output.Images); len(ids) > 0 {
+			return fmt.Errorf("images without OCI revision and created labels: %s", strings.Join(ids, ", "))
+		}
+	}
+	if *failUnhealthy {
+		if ids := fester.Unhealthy(output.AllContainers()); len(ids) > 0 {
+			return fmt.Errorf("unhealthy containers: %s", strings.Join(ids, ", "))
+		}
+	}
+	thresholds := fester.Thresholds{MaxExited: *maxExited, MinRunning: *minRunning, MaxImages: *maxImages}
+	if err := thresholds.Check(output); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestJSONIndent(t *testing.T) {
+	tests := []struct {
+		indent          int
+		compact, pretty bool
+		want            int
+	}{
+		{2, false, true, 2},
+		{4, false, true, 4},
+		{0, false, true, 0},
+		{-1, false, true, -1},
+		{2, true, true, 0},
+		{-1, true, true, 0},
+		{4, false, false, 0},
+		{-1, false, false, 0},
+	}
+	for _, tt := range tests {
+		if got := jsonIndent(tt.indent, tt.compact, tt.pretty); got != tt.want {
+			t.Errorf("jsonIndent(%d, %t, %t) = %d, want %d", tt.indent, tt.compact, tt.pretty, got, tt.want)
+		}
+	}
+}
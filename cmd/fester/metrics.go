@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"github.com/johnworth/fester"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// registry holds the metrics about collections served at /metrics.
+var registry = prometheus.NewRegistry()
+
+var (
+	imagesTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fester_images_total",
+		Help: "The number of images listed by the last successful collection.",
+	})
+	containersTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fester_containers_total",
+		Help: "The number of containers listed by the last successful collection.",
+	})
+	containersRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fester_containers_running",
+		Help: "The number of running containers listed by the last successful collection.",
+	})
+	collectionDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fester_collection_duration_seconds",
+		Help: "How long the last collection took, whether it succeeded or not.",
+	})
+	lastCollection = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fester_last_collection_timestamp",
+		Help: "When the last successful collection finished, in seconds since the Unix epoch.",
+	})
+	collectionErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "fester_collection_errors_total",
+		Help: "The number of collections that failed.",
+	})
+)
+
+func init() {
+	registry.MustRegister(imagesTotal, containersTotal, containersRunning, collectionDuration, lastCollection, collectionErrors)
+}
+
+// recordCollection updates the metrics after a collection that started at
+// start and returned output and err.
+func recordCollection(start time.Time, output *fester.OutputMap, err error) {
+	collectionDuration.Set(time.Since(start).Seconds())
+	if err != nil {
+		collectionErrors.Inc()
+		return
+	}
+	imagesTotal.Set(float64(output.Summary.ImageCount))
+	containersTotal.Set(float64(output.Summary.ContainerCount))
+	containersRunning.Set(float64(output.Summary.RunningContainerCount))
+	lastCollection.Set(float64(time.Now().Unix()))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// preset is a default a profile gives a flag. It doesn't apply if the flag,
+// or any of the flags in unless, was given on the command line, since those
+// would contradict or be overridden by it.
+type preset struct {
+	flag   string
+	value  string
+	unless []string
+}
+
+// fullPresets collects everything fester can describe about a host without
+// following logs or sampling stats, which are slow and rarely wanted in an
+// archived manifest.
+var fullPresets = []preset{
+	{flag: "disk-usage", value: "true"},
+	{flag: "inspect-containers", value: "true"},
+	{flag: "image-history", value: "true"},
+	{flag: "plugins", value: "true"},
+	{flag: "swarm", value: "true"},
+}
+
+// profiles maps each -profile name to the flag defaults it sets.
+var profiles = map[string][]preset{
+	// minimal lists only the running containers and the images they were
+	// started from, without system info, as compact JSON.
+	"minimal": {
+		{flag: "running-only", value: "true", unless: []string{"container-status", "list-containers"}},
+		{flag: "no-system-info", value: "true"},
+		{flag: "indent", value: "0", unless: []string{"pretty", "compact"}},
+	},
+	"full": fullPresets,
+	// audit is full, with the manifest's checksum written alongside it and
+	// common credential formats redacted.
+	"audit": append(append([]preset{}, fullPresets...),
+		preset{flag: "checksum-file", value: "true"},
+		preset{flag: "redact-common", value: "true"},
+	),
+}
+
+// profileNames returns the names of the profiles, sorted.
+func profileNames() []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the defaults of the named profile in fs, leaving alone
+// every flag given on the command line, so that an explicit flag always wins
+// over the profile, and the profile over fester's own defaults.
+func applyProfile(fs *flag.FlagSet, name string) error {
+	presets, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("--profile must be one of: %s", strings.Join(profileNames(), ", "))
+	}
+	// The flags given are noted before any are set, since flag.Set makes
+	// a flag look as if it was given.
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, p := range presets {
+		if given[p.flag] {
+			continue
+		}
+		skip := false
+		for _, other := range p.unless {
+			skip = skip || given[other]
+		}
+		if skip {
+			continue
+		}
+		if err := fs.Set(p.flag, p.value); err != nil {
+			return fmt.Errorf("--profile %s: setting --%s: %s", name, p.flag, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+// testValue is a flag.Value that holds whatever it's set to, standing in for
+// one of fester's flags so that each test case starts from the defaults.
+type testValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *testValue) String() string     { return v.value }
+func (v *testValue) Set(s string) error { v.value = s; return nil }
+func (v *testValue) IsBoolFlag() bool   { return v.isBool }
+
+// testFlags returns a flag.FlagSet with a testValue for each of fester's
+// flags, set to its default and then to args.
+func testFlags(t *testing.T, args ...string) *flag.FlagSet {
+	fs := flag.NewFlagSet("fester", flag.ContinueOnError)
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		fs.Var(&testValue{value: f.DefValue, isBool: ok && b.IsBoolFlag()}, f.Name, f.Usage)
+	})
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestApplyProfile(t *testing.T) {
+	full := map[string]string{
+		"disk-usage":         "true",
+		"inspect-containers": "true",
+		"image-history":      "true",
+		"plugins":            "true",
+		"swarm":              "true",
+	}
+	tests := []struct {
+		name    string
+		profile string
+		args    []string
+		want    map[string]string
+	}{
+		{
+			name:    "minimal",
+			profile: "minimal",
+			want:    map[string]string{"running-only": "true", "no-system-info": "true", "indent": "0"},
+		},
+		{"full", "full", nil, full},
+		{
+			name:    "audit",
+			profile: "audit",
+			want: map[string]string{
+				"disk-usage":         "true",
+				"inspect-containers": "true",
+				"image-history":      "true",
+				"plugins":            "true",
+				"swarm":              "true",
+				"checksum-file":      "true",
+				"redact-common":      "true",
+			},
+		},
+		{
+			name:    "an explicit flag wins over minimal",
+			profile: "minimal",
+			args:    []string{"-running-only=false", "-indent", "4"},
+			want:    map[string]string{"running-only": "false", "no-system-info": "true", "indent": "4"},
+		},
+		{
+			name:    "an explicit flag wins over full",
+			profile: "full",
+			args:    []string{"-swarm=false", "-image-history=false"},
+			want: map[string]string{
+				"disk-usage":         "true",
+				"inspect-containers": "true",
+				"image-history":      "false",
+				"plugins":            "true",
+				"swarm":              "false",
+			},
+		},
+		{
+			name:    "an explicit flag wins over audit",
+			profile: "audit",
+			args:    []string{"-redact-common=false"},
+			want:    map[string]string{"checksum-file": "true", "redact-common": "false"},
+		},
+		{
+			name:    "-container-status keeps minimal from listing only running containers",
+			profile: "minimal",
+			args:    []string{"-container-status", "exited"},
+			want:    map[string]string{"running-only": "false", "container-status": "exited", "no-system-info": "true"},
+		},
+		{
+			name:    "-list-containers keeps minimal from listing only running containers",
+			profile: "minimal",
+			args:    []string{"-list-containers=false"},
+			want:    map[string]string{"running-only": "false", "list-containers": "false"},
+		},
+		{
+			name:    "-pretty keeps minimal from setting -indent",
+			profile: "minimal",
+			args:    []string{"-pretty"},
+			want:    map[string]string{"indent": "2", "pretty": "true", "running-only": "true"},
+		},
+		{
+			name:    "-compact keeps minimal from setting -indent",
+			profile: "minimal",
+			args:    []string{"-compact"},
+			want:    map[string]string{"indent": "2", "compact": "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testFlags(t, tt.args...)
+			if err := applyProfile(fs, tt.profile); err != nil {
+				t.Fatalf("applyProfile(%q): %s", tt.profile, err)
+			}
+			for name, want := range tt.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("--%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyProfileLeavesOtherFlags(t *testing.T) {
+	fs := testFlags(t)
+	if err := applyProfile(fs, "minimal"); err != nil {
+		t.Fatal(err)
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, name := range []string{"disk-usage", "image-history", "checksum-file"} {
+		if set[name] {
+			t.Errorf("minimal set --%s", name)
+		}
+	}
+}
+
+func TestApplyProfileUnknown(t *testing.T) {
+	if err := applyProfile(testFlags(t), "everything"); err == nil {
+		t.Error("applyProfile accepted an unknown profile")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressInterval is how often the progress of the per-object Docker calls
+// is reported at most.
+const progressInterval = time.Second
+
+// progressReporter writes the progress of the per-object Docker calls, such
+// as container inspections, to a file, usually stderr. On a terminal the line
+// is updated in place; otherwise a line is written each time.
+type progressReporter struct {
+	mu   sync.Mutex
+	w    *os.File
+	tty  bool
+	last time.Time
+}
+
+// newProgressReporter returns a *progressReporter writing to w.
+func newProgressReporter(w *os.File) *progressReporter {
+	p := &progressReporter{w: w}
+	if info, err := w.Stat(); err == nil {
+		p.tty = info.Mode()&os.ModeCharDevice != 0
+	}
+	return p
+}
+
+// report reports that done of the total objects what describes are done. It
+// is rate-limited to once every progressInterval, except that the last one is
+// always reported.
+func (p *progressReporter) report(what string, done, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if done < total && now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+	if !p.tty {
+		fmt.Fprintf(p.w, "%s: %d/%d\n", what, done, total)
+		return
+	}
+	fmt.Fprintf(p.w, "\r\033[K%s: %d/%d", what, done, total)
+	if done == total {
+		fmt.Fprintln(p.w)
+	}
+}
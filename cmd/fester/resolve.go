@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/johnworth/fester"
+)
+
+// resolveMain runs the resolve subcommand with args, the arguments after
+// "resolve", and returns the exit code.
+func resolveMain(args []string) int {
+	resolveFlags := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	resolveDir := resolveFlags.String("dedup-dir", "", "The -dedup-dir the manifest was deduplicated against")
+	resolveFormat := resolveFlags.String("format", "json", "The format to write the manifest in, one of: "+strings.Join(fester.Formats, ", "))
+	resolveOutput := resolveFlags.String("output", "", "The file to write the manifest to. Defaults to stdout.")
+	resolveIndent := resolveFlags.Int("indent", 2, "The number of spaces to indent JSON with; 0 writes it compactly and less than 0 indents with tabs")
+	resolveFlags.Usage = func() {
+		fmt.Fprintln(resolveFlags.Output(), "Usage: fester resolve -dedup-dir dir [-format json|yaml|ndjson] [-indent n] [-output file] [<manifest>|-]")
+		resolveFlags.PrintDefaults()
+	}
+	if err := resolveFlags.Parse(args); err != nil {
+		return 2
+	}
+	if err := resolve(resolveFlags.Args(), *resolveDir, *resolveFormat, *resolveOutput, *resolveIndent); err != nil {
+		slog.Error("fester resolve failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// resolve reads the manifest named in args, or stdin if there is none,
+// reassembling it from dir if it's a pointer written by -dedup-dir, and writes
+// it in format to the output file, or stdout if it's empty.
+func resolve(args []string, dir, format, output string, indent int) error {
+	if len(args) > 1 {
+		return errors.New("resolve takes the path of at most one manifest")
+	}
+	if !fester.ValidFormat(format) {
+		return fmt.Errorf("-format must be one of: %s", strings.Join(fester.Formats, ", "))
+	}
+	filename := "-"
+	if len(args) == 1 {
+		filename = args[0]
+	}
+	manifest, err := fester.ResolveManifest(filename, dir)
+	if err != nil {
+		return fmt.Errorf("resolving manifest: %s", err)
+	}
+	content, err := manifest.Marshal(format, indent)
+	if err != nil {
+		return fmt.Errorf("marshalling manifest: %s", err)
+	}
+	return fester.WriteOutput(output, content)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/johnworth/fester"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// contentTypes maps each output format to the Content-Type it is served as.
+var contentTypes = map[string]string{
+	"json":   "application/json",
+	"yaml":   "application/yaml",
+	"ndjson": "application/x-ndjson",
+}
+
+// newServeMux returns an *http.ServeMux with the manifest, health check, and
+// metrics endpoints. The health check fails if any of hosts can't be reached.
+func newServeMux(hosts []fester.Host, opts fester.Options) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manifest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		output, err := collect(r.Context(), hosts, opts)
+		if err != nil {
+			slog.Error("collecting manifest failed", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content, err := encode(output)
+		if err != nil {
+			slog.Error("encoding manifest failed", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType())
+		w.Write(content)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if *timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, *timeout)
+			defer cancel()
+		}
+		for _, h := range hosts {
+			if _, err := h.Client.APIVersion(ctx); err != nil {
+				http.Error(w, fmt.Sprintf("%s: %s", h.URI, err), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// serve serves the manifest over HTTP on addr until ctx is done. Requests in
+// progress are then given up to the -shutdown-timeout to finish.
+func serve(ctx context.Context, addr string, hosts []fester.Host, opts fester.Options) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: newServeMux(hosts, opts),
+	}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(sctx); err != nil {
+			slog.Warn("HTTP requests didn't finish in time", "error", err)
+			srv.Close()
+		}
+	}()
+	slog.Info("serving the manifest", "addr", addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	<-shutdown
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+
+	"github.com/johnworth/fester"
+)
+
+// verifyMain runs the verify subcommand with args, the arguments after
+// "verify", and returns the exit code: 0 if the signature is good, 1 if it
+// isn't, and 2 if it couldn't be checked.
+func verifyMain(args []string) int {
+	err := verify(args)
+	if err == fester.ErrBadSignature {
+		slog.Error("signature verification failed", "manifest", args[0])
+		return 1
+	}
+	if err != nil {
+		slog.Error("fester verify failed", "error", err)
+		return 2
+	}
+	if !*quiet {
+		fmt.Println("signature OK")
+	}
+	return 0
+}
+
+// verify checks the signature of a manifest. args are the paths of the
+// manifest, its signature, and the public key.
+func verify(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: fester verify <manifest> <signature> <public-key>")
+	}
+	var files [3][]byte
+	for n, path := range args {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[n] = content
+	}
+	return fester.VerifySignature(files[0], files[1], files[2])
+}
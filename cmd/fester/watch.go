@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/johnworth/fester"
+)
+
+// maxWatchBackoff is the longest watchEvents waits before reconnecting to a
+// daemon's event stream.
+const maxWatchBackoff = time.Minute
+
+// hostEvent is an event reported by one of the hosts being watched. Event is
+// nil if the host's event stream failed, so events may have been missed.
+type hostEvent struct {
+	Host  fester.Host
+	Event *fester.Event
+}
+
+// watchEvents streams the events of each of hosts that change the manifest
+// until ctx is done, sending each of them on the returned channel. If a
+// stream fails a hostEvent without an Event is sent, and the stream is
+// reconnected, waiting -retry-interval at first and twice as long after each
+// failure in a row.
+func watchEvents(ctx context.Context, hosts []fester.Host) <-chan hostEvent {
+	events := make(chan hostEvent, 64)
+	send := func(e hostEvent) {
+		select {
+		case events <- e:
+		case <-ctx.Done():
+		}
+	}
+	for _, h := range hosts {
+		go func(h fester.Host) {
+			backoff := *retryInterval
+			for {
+				err := h.Client.Events(ctx, fester.WatchFilters, func(e *fester.Event) {
+					backoff = *retryInterval
+					slog.Debug("got Docker event", "uri", h.URI, "type", e.Type, "action", e.Action, "id", e.ID)
+					send(hostEvent{Host: h, Event: e})
+				})
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("watching Docker events failed, reconnecting", "uri", h.URI, "error", err, "wait", backoff)
+				send(hostEvent{Host: h})
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				if backoff *= 2; backoff > maxWatchBackoff {
+					backoff = maxWatchBackoff
+				}
+			}
+		}(h)
+	}
+	return events
+}
+
+// applyEvents applies events to output, the manifest collected last, as
+// fester.OutputMap.Update does, and redacts what they added as collect does.
+// It returns false if any of them can't be applied, in which case the
+// manifest has to be collected again.
+func applyEvents(ctx context.Context, output *fester.OutputMap, events []hostEvent, opts fester.Options) bool {
+	for _, e := range events {
+		if e.Event == nil {
+			slog.Info("events may have been missed, collecting the manifest again", "uri", e.Host.URI)
+			return false
+		}
+		if err := output.Update(ctx, e.Host.Client, e.Host.URI, e.Event, opts); err != nil {
+			slog.Info("couldn't apply event, collecting the manifest again", "uri", e.Host.URI, "type", e.Event.Type, "action", e.Event.Action, "id", e.Event.ID, "error", err)
+			return false
+		}
+	}
+	output.Redact(redactions)
+	return true
+}
@@ -0,0 +1,44 @@
+package fester
+
+import "sort"
+
+// ComposeProjectLabel is the label Docker Compose puts the name of the project
+// in on the containers it starts.
+const ComposeProjectLabel = "com.docker.compose.project"
+
+// StandaloneProject is the key GroupByCompose puts containers that weren't
+// started by Docker Compose under.
+const StandaloneProject = "_standalone"
+
+// GroupByCompose moves the containers into Projects, keyed by the name of the
+// Docker Compose project that started them. Containers that weren't started by
+// Compose are put under StandaloneProject. Containers is left empty.
+func (o *OutputMap) GroupByCompose() {
+	o.Projects = make(map[string][]*Container)
+	for _, c := range o.Containers {
+		project := c.Labels[ComposeProjectLabel]
+		if project == "" {
+			project = StandaloneProject
+		}
+		o.Projects[project] = append(o.Projects[project], c)
+	}
+	o.Containers = []*Container{}
+}
+
+// AllContainers returns the containers in the manifest, whether they are
+// listed in Containers or grouped into Projects.
+func (o *OutputMap) AllContainers() []*Container {
+	if len(o.Projects) == 0 {
+		return o.Containers
+	}
+	var names []string
+	for name := range o.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	containers := append([]*Container{}, o.Containers...)
+	for _, name := range names {
+		containers = append(containers, o.Projects[name]...)
+	}
+	return containers
+}
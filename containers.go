@@ -0,0 +1,328 @@
+package fester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Port is a port exposed by a container, along with where it is published on
+// the host if it is.
+type Port struct {
+	IP          string `json:"IP,omitempty" yaml:"IP,omitempty"`
+	PrivatePort int    `json:"PrivatePort" yaml:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort,omitempty" yaml:"PublicPort,omitempty"`
+	Type        string `json:"Type" yaml:"Type"`
+}
+
+// String returns the port mapping as docker ps shows it, for example
+// 0.0.0.0:8080->80/tcp, or just 80/tcp if the port isn't published.
+func (p Port) String() string {
+	container := fmt.Sprintf("%d/%s", p.PrivatePort, p.Type)
+	if p.PublicPort == 0 {
+		return container
+	}
+	host := strconv.Itoa(p.PublicPort)
+	if p.IP != "" {
+		host = net.JoinHostPort(p.IP, host)
+	}
+	return host + "->" + container
+}
+
+// Container contains the info reported by docker about a container on the
+// host.
+type Container struct {
+	ID      string   `json:"Id" yaml:"Id"`
+	Names   []string `json:"Names" yaml:"Names"`
+	Image   string   `json:"Image" yaml:"Image"`
+	ImageID string   `json:"ImageID" yaml:"ImageID"`
+	Command string   `json:"Command" yaml:"Command"`
+	Created int64    `json:"Created" yaml:"Created"`
+	Ports   []Port   `json:"Ports" yaml:"Ports"`
+	// PortSummary lists the published ports as host-port->container-port
+	// mappings, such as 0.0.0.0:8080->80/tcp.
+	PortSummary []string          `json:"PortSummary" yaml:"PortSummary"`
+	Labels      map[string]string `json:"Labels" yaml:"Labels"`
+	State       string            `json:"State" yaml:"State"`
+	// Networks maps the name of each network the container is attached to,
+	// including the default bridge, to its address on it.
+	Networks map[string]*NetworkAttachment `json:"Networks" yaml:"Networks"`
+	// Env, Cmd, Entrypoint, Mounts, Health, RestartCount, and ExitCode are
+	// only filled in when containers are inspected individually. ExitCode is
+	// the code the container last exited with.
+	Env          []string `json:"Env,omitempty" yaml:"Env,omitempty"`
+	Cmd          []string `json:"Cmd,omitempty" yaml:"Cmd,omitempty"`
+	Entrypoint   []string `json:"Entrypoint,omitempty" yaml:"Entrypoint,omitempty"`
+	Mounts       []Mount  `json:"Mounts,omitempty" yaml:"Mounts,omitempty"`
+	Health       *Health  `json:"Health,omitempty" yaml:"Health,omitempty"`
+	RestartCount int      `json:"RestartCount,omitempty" yaml:"RestartCount,omitempty"`
+	ExitCode     int      `json:"ExitCode,omitempty" yaml:"ExitCode,omitempty"`
+	// Stats is only filled in for running containers when stats are
+	// collected.
+	Stats *ContainerStats `json:"Stats,omitempty" yaml:"Stats,omitempty"`
+	// Logs holds the last lines the container logged, when logs are
+	// captured. LogsTruncated is set if they were cut short, and LogsError
+	// says why they couldn't be read if they couldn't.
+	Logs          string `json:"Logs,omitempty" yaml:"Logs,omitempty"`
+	LogsTruncated bool   `json:"LogsTruncated,omitempty" yaml:"LogsTruncated,omitempty"`
+	LogsError     string `json:"LogsError,omitempty" yaml:"LogsError,omitempty"`
+	SourceURI     string `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// NetworkAttachment is a container's attachment to a network.
+type NetworkAttachment struct {
+	NetworkID  string   `json:"NetworkID" yaml:"NetworkID"`
+	IPAddress  string   `json:"IPAddress" yaml:"IPAddress"`
+	Gateway    string   `json:"Gateway" yaml:"Gateway"`
+	MacAddress string   `json:"MacAddress" yaml:"MacAddress"`
+	Aliases    []string `json:"Aliases" yaml:"Aliases"`
+}
+
+// containerInspect is the subset of docker container inspect output used to
+// build a Container.
+type containerInspect struct {
+	ID      string `json:"Id"`
+	Name    string
+	Image   string
+	Path    string
+	Args    []string
+	Created time.Time
+	State   struct {
+		Status string
+	}
+	Config struct {
+		Image  string
+		Labels map[string]string
+	}
+	NetworkSettings struct {
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string
+		}
+		Networks map[string]*NetworkAttachment
+	}
+}
+
+// newContainer returns the *Container described by the inspect output.
+func newContainer(i *containerInspect) *Container {
+	c := &Container{
+		ID:       i.ID,
+		Names:    []string{i.Name},
+		Image:    i.Config.Image,
+		ImageID:  i.Image,
+		Command:  strings.TrimSpace(i.Path + " " + strings.Join(i.Args, " ")),
+		Created:  i.Created.Unix(),
+		Ports:    []Port{},
+		Labels:   i.Config.Labels,
+		State:    i.State.Status,
+		Networks: i.NetworkSettings.Networks,
+	}
+	if c.Networks == nil {
+		c.Networks = map[string]*NetworkAttachment{}
+	}
+	for _, n := range c.Networks {
+		if n.Aliases == nil {
+			n.Aliases = []string{}
+		}
+	}
+	for spec, bindings := range i.NetworkSettings.Ports {
+		port, proto := spec, "tcp"
+		if n := strings.Index(spec, "/"); n >= 0 {
+			port, proto = spec[:n], spec[n+1:]
+		}
+		private, _ := strconv.Atoi(port)
+		if len(bindings) == 0 {
+			c.Ports = append(c.Ports, Port{PrivatePort: private, Type: proto})
+		}
+		for _, b := range bindings {
+			public, _ := strconv.Atoi(b.HostPort)
+			c.Ports = append(c.Ports, Port{IP: b.HostIP, PrivatePort: private, PublicPort: public, Type: proto})
+		}
+	}
+	sort.Slice(c.Ports, func(a, b int) bool {
+		pa, pb := c.Ports[a], c.Ports[b]
+		if pa.PrivatePort != pb.PrivatePort {
+			return pa.PrivatePort < pb.PrivatePort
+		}
+		if pa.Type != pb.Type {
+			return pa.Type < pb.Type
+		}
+		return pa.PublicPort < pb.PublicPort
+	})
+	c.PortSummary = []string{}
+	for _, p := range c.Ports {
+		if p.PublicPort != 0 {
+			c.PortSummary = append(c.PortSummary, p.String())
+		}
+	}
+	return c
+}
+
+// Mount is a volume, bind mount, or tmpfs mounted into a container. For bind
+// mounts Source is the path on the host.
+type Mount struct {
+	Type        string `json:"Type" yaml:"Type"`
+	Name        string `json:"Name,omitempty" yaml:"Name,omitempty"`
+	Source      string `json:"Source" yaml:"Source"`
+	Destination string `json:"Destination" yaml:"Destination"`
+	Mode        string `json:"Mode" yaml:"Mode"`
+	RW          bool   `json:"RW" yaml:"RW"`
+}
+
+// HealthLogEntries is the number of the most recent health check results
+// kept for each container.
+const HealthLogEntries = 5
+
+// Health is the state of a container's health check. Status is healthy,
+// unhealthy, or starting, or none if the container has no health check.
+type Health struct {
+	Status string         `json:"Status" yaml:"Status"`
+	Log    []*HealthCheck `json:"Log,omitempty" yaml:"Log,omitempty"`
+}
+
+// HealthCheck is the result of one run of a container's health check.
+type HealthCheck struct {
+	Start    time.Time `json:"Start" yaml:"Start"`
+	End      time.Time `json:"End" yaml:"End"`
+	ExitCode int       `json:"ExitCode" yaml:"ExitCode"`
+	Output   string    `json:"Output" yaml:"Output"`
+}
+
+// ContainerDetails is the configuration of a container that isn't part of a
+// Container.
+type ContainerDetails struct {
+	Env          []string
+	Cmd          []string
+	Entrypoint   []string
+	Mounts       []Mount
+	Health       *Health
+	RestartCount int
+	ExitCode     int
+}
+
+// Apply copies the details into c, replacing the value of every environment
+// variable whose name contains one of redact, ignoring case, with ***.
+func (d *ContainerDetails) Apply(c *Container, redact []string) {
+	c.Env = RedactEnv(d.Env, redact)
+	c.Cmd = d.Cmd
+	c.Entrypoint = d.Entrypoint
+	c.Mounts = d.Mounts
+	c.Health = d.Health
+	c.RestartCount = d.RestartCount
+	c.ExitCode = d.ExitCode
+}
+
+// RedactEnv returns a copy of env, a list of KEY=value pairs, with the value
+// of every variable whose name contains one of redact, ignoring case, replaced
+// with ***.
+func RedactEnv(env []string, redact []string) []string {
+	if env == nil {
+		return nil
+	}
+	redacted := make([]string, 0, len(env))
+	for _, kv := range env {
+		key := kv
+		if n := strings.Index(kv, "="); n >= 0 {
+			key = kv[:n]
+		}
+		for _, r := range redact {
+			if r != "" && strings.Contains(strings.ToUpper(key), strings.ToUpper(r)) {
+				kv = key + "=***"
+				break
+			}
+		}
+		redacted = append(redacted, kv)
+	}
+	return redacted
+}
+
+// SortContainers sorts containers by their first name, and then by ID.
+func SortContainers(containers []*Container) {
+	sort.SliceStable(containers, func(a, b int) bool {
+		na, nb := firstOf(containers[a].Names), firstOf(containers[b].Names)
+		if na != nb {
+			return na < nb
+		}
+		return containers[a].ID < containers[b].ID
+	})
+}
+
+// Unhealthy returns the IDs of the containers whose health check is failing.
+func Unhealthy(containers []*Container) []string {
+	var ids []string
+	for _, c := range containers {
+		if c.Health != nil && c.Health.Status == "unhealthy" {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids
+}
+
+// ContainerStates lists the states a container can be in.
+var ContainerStates = []string{"created", "restarting", "running", "removing", "paused", "exited", "dead"}
+
+// ValidContainerState returns true if state is one of ContainerStates.
+func ValidContainerState(state string) bool {
+	for _, s := range ContainerStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// ListContainers returns the containers on the Docker host that match
+// filters, whether they are running or not.
+func (c *Client) ListContainers(ctx context.Context, filters Filters) ([]*Container, error) {
+	var inspected []*containerInspect
+	args := append([]string{"--all", "--no-trunc"}, filters.Args()...)
+	if err := c.inspect(ctx, "container", &inspected, args...); err != nil {
+		return nil, err
+	}
+	containers := []*Container{}
+	for _, i := range inspected {
+		containers = append(containers, newContainer(i))
+	}
+	return containers, nil
+}
+
+// InspectContainer returns the details of the container with the given ID.
+func (c *Client) InspectContainer(ctx context.Context, id string) (*ContainerDetails, error) {
+	stdout, stderr, err := c.Output(ctx, "container", "inspect", id)
+	if err != nil {
+		return nil, err
+	}
+	logWarnings(stderr)
+	var inspected []struct {
+		Config       ContainerDetails
+		Mounts       []Mount
+		RestartCount int
+		State        struct {
+			Health   *Health
+			ExitCode int
+		}
+	}
+	if err = json.Unmarshal(stdout, &inspected); err != nil {
+		return nil, err
+	}
+	if len(inspected) == 0 {
+		return nil, fmt.Errorf("no such container: %s", id)
+	}
+	d := &inspected[0].Config
+	d.Mounts = inspected[0].Mounts
+	d.Health = inspected[0].State.Health
+	d.RestartCount = inspected[0].RestartCount
+	d.ExitCode = inspected[0].State.ExitCode
+	if d.Health == nil {
+		d.Health = &Health{Status: "none"}
+	}
+	if n := len(d.Health.Log); n > HealthLogEntries {
+		d.Health.Log = d.Health.Log[n-HealthLogEntries:]
+	}
+	return d, nil
+}
@@ -0,0 +1,104 @@
+package fester
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EpochFormat is the date format that records the date as a Unix timestamp.
+const EpochFormat = "epoch"
+
+// Timestamp is the date a manifest was collected. It is encoded as a string,
+// or as a number of seconds since the Unix epoch if Epoch is set.
+type Timestamp struct {
+	Value string
+	Epoch bool
+	// time is the time the Timestamp was made from, if it's known.
+	time time.Time
+}
+
+// NewTimestamp returns t formatted with layout, a Go time layout or
+// EpochFormat. The time is converted to UTC first if utc is set.
+func NewTimestamp(t time.Time, layout string, utc bool) Timestamp {
+	if utc {
+		t = t.UTC()
+	}
+	if layout == EpochFormat {
+		return Timestamp{Value: strconv.FormatInt(t.Unix(), 10), Epoch: true, time: t}
+	}
+	return Timestamp{Value: t.Format(layout), time: t}
+}
+
+// Unix returns the date as a Unix timestamp. For a Timestamp read back from a
+// manifest this only works if it is an epoch or in RFC3339 form; otherwise
+// zero is returned.
+func (t Timestamp) Unix() int64 {
+	if !t.time.IsZero() {
+		return t.time.Unix()
+	}
+	if t.Epoch {
+		n, _ := strconv.ParseInt(t.Value, 10, 64)
+		return n
+	}
+	if parsed, err := time.Parse(time.RFC3339, t.Value); err == nil {
+		return parsed.Unix()
+	}
+	return 0
+}
+
+// ParseTime parses s as an RFC3339 date, or as a duration such as 720h that
+// is subtracted from now.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("parsing time %q: must be an RFC3339 date or a duration such as 720h", s)
+}
+
+// String returns the formatted date.
+func (t Timestamp) String() string {
+	return t.Value
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.Epoch {
+		return []byte(t.Value), nil
+	}
+	return json.Marshal(t.Value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		t.Epoch = false
+		return json.Unmarshal(data, &t.Value)
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	t.Value, t.Epoch = n.String(), true
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (t Timestamp) MarshalYAML() (interface{}, error) {
+	if t.Epoch {
+		return strconv.ParseInt(t.Value, 10, 64)
+	}
+	return t.Value, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (t *Timestamp) UnmarshalYAML(node *yaml.Node) error {
+	t.Value, t.Epoch = node.Value, node.Tag == "!!int"
+	return nil
+}
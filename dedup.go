@@ -0,0 +1,140 @@
+package fester
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Pointer stands in for a manifest whose content is stored in a dedup dir,
+// written in its place when the same content was stored already. The content
+// is stored without the date and timings, so Pointer records them, along with
+// the hostname to tell which host it's for. SourceTimings holds the timings of
+// each of the manifest's Sources, in order.
+type Pointer struct {
+	Hostname      string               `json:"hostname"`
+	Date          Timestamp            `json:"date"`
+	Timings       map[string]float64   `json:"timings,omitempty"`
+	SourceTimings []map[string]float64 `json:"source_timings,omitempty"`
+	SHA256        string               `json:"dedup_sha256"`
+	Format        string               `json:"dedup_format"`
+}
+
+// Dedup stores the manifest in dir, in a file named after the SHA-256 of
+// its content in format with the date and timings left out, so that
+// manifests that differ only in when they were collected, and how long that
+// took, are stored once. content is the manifest as it is to be written out.
+// If the same content was stored already, a Pointer to it is returned as JSON
+// to be written instead; otherwise content is returned as it is.
+func (o *OutputMap) Dedup(dir, format string, indent int, content []byte) ([]byte, error) {
+	if format != "json" && format != "yaml" {
+		return nil, fmt.Errorf("can't deduplicate %s manifests, only json or yaml", format)
+	}
+	stored := *o
+	stored.Date = Timestamp{}
+	stored.Timings = nil
+	var sourceTimings []map[string]float64
+	if len(o.Sources) > 0 {
+		stored.Sources = make([]*Source, len(o.Sources))
+		for n, s := range o.Sources {
+			c := *s
+			c.Timings = nil
+			stored.Sources[n] = &c
+			sourceTimings = append(sourceTimings, s.Timings)
+		}
+	}
+	blob, err := stored.Marshal(format, indent)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(blob)
+	p := &Pointer{
+		Hostname:      o.Hostname,
+		Date:          o.Date,
+		Timings:       o.Timings,
+		SourceTimings: sourceTimings,
+		SHA256:        hex.EncodeToString(sum[:]),
+		Format:        format,
+	}
+	path := p.path(dir)
+	if _, err = os.Stat(path); err == nil {
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err = WriteOutput(path, blob); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// path returns the path of the file in dir that p points to.
+func (p *Pointer) path(dir string) string {
+	return filepath.Join(dir, p.SHA256+"."+p.Format)
+}
+
+// Resolve reads the manifest p points to from dir, with the date and timings
+// p records.
+func (p *Pointer) Resolve(dir string) (*OutputMap, error) {
+	if p.Format != "json" && p.Format != "yaml" {
+		return nil, fmt.Errorf("unknown dedup format %q", p.Format)
+	}
+	path := p.path(dir)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if sum := sha256.Sum256(content); hex.EncodeToString(sum[:]) != p.SHA256 {
+		return nil, fmt.Errorf("the content of %s doesn't match its checksum", path)
+	}
+	o, err := parseManifest(path, content)
+	if err != nil {
+		return nil, err
+	}
+	o.Date = p.Date
+	o.Timings = p.Timings
+	for n, s := range o.Sources {
+		if n < len(p.SourceTimings) {
+			s.Timings = p.SourceTimings[n]
+		}
+	}
+	return o, nil
+}
+
+// parsePointer returns the Pointer in content, or nil if content isn't one.
+func parsePointer(content []byte) *Pointer {
+	if !bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return nil
+	}
+	var p Pointer
+	if err := json.Unmarshal(content, &p); err != nil || p.SHA256 == "" {
+		return nil
+	}
+	return &p
+}
+
+// ResolveManifest reads the manifest in filename like ReadManifest does. If
+// the file holds a Pointer written by Dedup instead, the manifest it points
+// to is read from dir.
+func ResolveManifest(filename, dir string) (*OutputMap, error) {
+	content, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if p := parsePointer(content); p != nil {
+		if dir == "" {
+			return nil, fmt.Errorf("%s points to a deduplicated manifest, but no dedup dir was given", filename)
+		}
+		return p.Resolve(dir)
+	}
+	return parseManifest(filename, content)
+}
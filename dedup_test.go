@@ -0,0 +1,156 @@
+package fester
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// collectAt returns a manifest collected from the fake host, dated at unix
+// seconds and with the given total time.
+func collectAt(t *testing.T, fake *fakeDocker, unix int64, total float64) *OutputMap {
+	o, err := Collect(context.Background(), fake, testOptions())
+	if err != nil {
+		t.Fatalf("Collect: %s", err)
+	}
+	o.Date = NewTimestamp(time.Unix(unix, 0), time.RFC3339, true)
+	o.Timings = map[string]float64{"total": total}
+	return o
+}
+
+// storedFiles returns the names of the files in dir.
+func storedFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestDedup(t *testing.T) {
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			fake := &fakeDocker{images: testImages(), containers: testContainers()}
+			dedup := func(o *OutputMap) []byte {
+				content, err := o.Marshal(format, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				written, err := o.Dedup(dir, format, 2, content)
+				if err != nil {
+					t.Fatalf("Dedup: %s", err)
+				}
+				if parsePointer(written) == nil && !bytes.Equal(written, content) {
+					t.Errorf("Dedup changed the manifest it didn't replace")
+				}
+				return written
+			}
+
+			// The first write stores the content and writes the manifest.
+			first := collectAt(t, fake, 1000, 1.5)
+			if p := parsePointer(dedup(first)); p != nil {
+				t.Fatalf("the first write is a pointer: %+v", p)
+			}
+			if files := storedFiles(t, dir); len(files) != 1 {
+				t.Fatalf("stored %q, want one file", files)
+			}
+
+			// A repeat that differs only in its date and timings is a
+			// pointer to the stored content.
+			repeat := collectAt(t, fake, 2000, 2.5)
+			p := parsePointer(dedup(repeat))
+			if p == nil {
+				t.Fatal("the repeat write isn't a pointer")
+			}
+			if p.Hostname != "test-host" || p.Format != format || p.Date.String() != repeat.Date.String() || p.Timings["total"] != 2.5 {
+				t.Errorf("pointer = %+v", p)
+			}
+			if files := storedFiles(t, dir); len(files) != 1 || files[0] != p.SHA256+"."+format {
+				t.Errorf("stored %q, want only %s.%s", files, p.SHA256, format)
+			}
+			resolved, err := p.Resolve(dir)
+			if err != nil {
+				t.Fatalf("Resolve: %s", err)
+			}
+			if resolved.Date.String() != repeat.Date.String() || !reflect.DeepEqual(resolved.Timings, repeat.Timings) {
+				t.Errorf("resolved date and timings = %v, %v, want %v, %v", resolved.Date, resolved.Timings, repeat.Date, repeat.Timings)
+			}
+			if len(resolved.Images) != len(repeat.Images) || resolved.Hostname != repeat.Hostname {
+				t.Errorf("resolved %d images for %q, want %d for %q", len(resolved.Images), resolved.Hostname, len(repeat.Images), repeat.Hostname)
+			}
+
+			// A change to the content is stored again.
+			fake.images = fake.images[:2]
+			if p := parsePointer(dedup(collectAt(t, fake, 3000, 1))); p != nil {
+				t.Error("a changed manifest is a pointer")
+			}
+			if files := storedFiles(t, dir); len(files) != 2 {
+				t.Errorf("stored %q, want two files", files)
+			}
+		})
+	}
+}
+
+func TestDedupNDJSON(t *testing.T) {
+	o := collectAt(t, &fakeDocker{}, 1000, 1)
+	if _, err := o.Dedup(t.TempDir(), "ndjson", 0, nil); err == nil {
+		t.Error("Dedup of an ndjson manifest succeeded")
+	}
+}
+
+func TestResolveManifest(t *testing.T) {
+	dir := t.TempDir()
+	fake := &fakeDocker{images: testImages(), containers: testContainers()}
+	o := collectAt(t, fake, 1000, 1)
+	content, err := o.Marshal("json", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = o.Dedup(dir, "json", 2, content); err != nil {
+		t.Fatal(err)
+	}
+	repeat := collectAt(t, fake, 2000, 2)
+	pointer, err := repeat.Dedup(dir, "json", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err = os.WriteFile(path, pointer, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := ResolveManifest(path, dir)
+	if err != nil {
+		t.Fatalf("ResolveManifest: %s", err)
+	}
+	if resolved.Date.String() != repeat.Date.String() {
+		t.Errorf("resolved date = %v, want %v", resolved.Date, repeat.Date)
+	}
+	if _, err = ResolveManifest(path, ""); err == nil {
+		t.Error("ResolveManifest of a pointer without a dedup dir succeeded")
+	}
+	// A manifest that isn't a pointer is read as it is.
+	full := filepath.Join(t.TempDir(), "full.json")
+	if err = os.WriteFile(full, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if resolved, err = ResolveManifest(full, dir); err != nil || resolved.Date.String() != o.Date.String() {
+		t.Errorf("ResolveManifest of a full manifest = %v, %v", resolved, err)
+	}
+	// Stored content that was changed since is refused.
+	p := parsePointer(pointer)
+	if err = os.WriteFile(p.path(dir), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Resolve(dir); err == nil {
+		t.Error("Resolve of changed content succeeded")
+	}
+}
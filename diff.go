@@ -0,0 +1,300 @@
+package fester
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadManifest reads a manifest written by fester as JSON or YAML, optionally
+// compressed with gzip. A filename of "-" reads from stdin.
+func ReadManifest(filename string) (*OutputMap, error) {
+	content, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(filename, content)
+}
+
+// readFile reads the file, or stdin if filename is "-", decompressing it if
+// it's compressed with gzip.
+func readFile(filename string) ([]byte, error) {
+	var content []byte
+	var err error
+	if filename == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(content, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		if content, err = ioutil.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+// parseManifest parses content, the manifest read from filename, as JSON or
+// YAML.
+func parseManifest(filename string, content []byte) (*OutputMap, error) {
+	o := &OutputMap{}
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		err = json.Unmarshal(content, o)
+	} else {
+		err = yaml.Unmarshal(content, o)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", filename, err)
+	}
+	return o, nil
+}
+
+// FieldChange is a field whose value differs between two manifests.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Change describes how an image or container with the same ID, on the same
+// host, differs between two manifests. SourceURI is only set for manifests
+// aggregated from several hosts.
+type Change struct {
+	ID        string         `json:"id"`
+	SourceURI string         `json:"source_uri,omitempty"`
+	Fields    []*FieldChange `json:"fields"`
+}
+
+// Diff lists the images and containers that were added, removed, or changed
+// between two manifests. Images and containers are matched up by ID, so a
+// renamed container shows up as a change to its Names. Containers grouped
+// into Compose projects are compared just like the others.
+type Diff struct {
+	AddedImages       []*Image     `json:"added_images"`
+	RemovedImages     []*Image     `json:"removed_images"`
+	ChangedImages     []*Change    `json:"changed_images"`
+	AddedContainers   []*Container `json:"added_containers"`
+	RemovedContainers []*Container `json:"removed_containers"`
+	ChangedContainers []*Change    `json:"changed_containers"`
+}
+
+// Ignore lists the fields of images and containers to leave out when
+// comparing manifests, keyed by "image" or "container", and the top-level
+// fields of the manifest, keyed by "manifest". The fields are Go field names.
+type Ignore map[string]map[string]bool
+
+// ParseIgnore parses fields, each of them image.<field>, container.<field>,
+// or a top-level field of the manifest, into an Ignore. The field may be given
+// by its Go or JSON name, in any case, as in container.state, image.RepoTags,
+// or date, and host is taken as hostname. The top-level fields are never
+// compared, so ignoring them changes nothing, but they are accepted so that a
+// list such as date,container.State works.
+func ParseIgnore(fields []string) (Ignore, error) {
+	types := map[string]reflect.Type{
+		"manifest":  reflect.TypeOf(OutputMap{}),
+		"image":     reflect.TypeOf(Image{}),
+		"container": reflect.TypeOf(Container{}),
+	}
+	ignore := Ignore{}
+	for _, f := range fields {
+		kind, field, ok := strings.Cut(f, ".")
+		if !ok {
+			kind, field = "manifest", f
+		}
+		kind = strings.ToLower(kind)
+		t, ok := types[kind]
+		if !ok {
+			return nil, fmt.Errorf("can't ignore %q: must be a top-level field, image.<field>, or container.<field>", f)
+		}
+		if kind == "manifest" && strings.EqualFold(field, "host") {
+			field = "hostname"
+		}
+		name, ok := fieldName(t, field)
+		if !ok {
+			return nil, fmt.Errorf("can't ignore %q: %s has no field %s", f, kind, field)
+		}
+		if ignore[kind] == nil {
+			ignore[kind] = make(map[string]bool)
+		}
+		ignore[kind][name] = true
+	}
+	return ignore, nil
+}
+
+// fieldName returns the Go name of the field of t whose Go or JSON name is
+// name, ignoring case.
+func fieldName(t reflect.Type, name string) (string, bool) {
+	for n := 0; n < t.NumField(); n++ {
+		f := t.Field(n)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if strings.EqualFold(f.Name, name) || strings.EqualFold(tag, name) {
+			return f.Name, true
+		}
+	}
+	return "", false
+}
+
+// Compare returns the differences between the before and after manifests.
+func Compare(before, after *OutputMap) *Diff {
+	return CompareIgnoring(before, after, nil)
+}
+
+// CompareIgnoring returns the differences between the before and after
+// manifests like Compare does, except that changes to the fields in ignore
+// don't count.
+func CompareIgnoring(before, after *OutputMap, ignore Ignore) *Diff {
+	d := &Diff{
+		AddedImages:       []*Image{},
+		RemovedImages:     []*Image{},
+		ChangedImages:     []*Change{},
+		AddedContainers:   []*Container{},
+		RemovedContainers: []*Container{},
+		ChangedContainers: []*Change{},
+	}
+	// Objects are matched up by host as well as ID, since an aggregated
+	// manifest can list the same image, or even container, on several
+	// hosts.
+	oldImages := make(map[[2]string]*Image)
+	for _, i := range before.Images {
+		oldImages[[2]string{i.SourceURI, i.ID}] = i
+	}
+	newImages := make(map[[2]string]*Image)
+	for _, i := range after.Images {
+		key := [2]string{i.SourceURI, i.ID}
+		newImages[key] = i
+		o, ok := oldImages[key]
+		if !ok {
+			d.AddedImages = append(d.AddedImages, i)
+		} else if fields := changedFields(o, i, ignore["image"]); len(fields) > 0 {
+			d.ChangedImages = append(d.ChangedImages, &Change{ID: i.ID, SourceURI: i.SourceURI, Fields: fields})
+		}
+	}
+	for _, i := range before.Images {
+		if _, ok := newImages[[2]string{i.SourceURI, i.ID}]; !ok {
+			d.RemovedImages = append(d.RemovedImages, i)
+		}
+	}
+	oldContainers := make(map[[2]string]*Container)
+	for _, c := range before.AllContainers() {
+		oldContainers[[2]string{c.SourceURI, c.ID}] = c
+	}
+	newContainers := make(map[[2]string]*Container)
+	for _, c := range after.AllContainers() {
+		key := [2]string{c.SourceURI, c.ID}
+		newContainers[key] = c
+		o, ok := oldContainers[key]
+		if !ok {
+			d.AddedContainers = append(d.AddedContainers, c)
+		} else if fields := changedFields(o, c, ignore["container"]); len(fields) > 0 {
+			d.ChangedContainers = append(d.ChangedContainers, &Change{ID: c.ID, SourceURI: c.SourceURI, Fields: fields})
+		}
+	}
+	for _, c := range before.AllContainers() {
+		if _, ok := newContainers[[2]string{c.SourceURI, c.ID}]; !ok {
+			d.RemovedContainers = append(d.RemovedContainers, c)
+		}
+	}
+	return d
+}
+
+// changedFields returns the fields other than ID and those in ignore that
+// differ between before and after, which must be pointers to the same type of
+// struct.
+func changedFields(before, after interface{}, ignore map[string]bool) []*FieldChange {
+	ov, nv := reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem()
+	var fields []*FieldChange
+	for n := 0; n < ov.NumField(); n++ {
+		name := ov.Type().Field(n).Name
+		if name == "ID" || ignore[name] {
+			continue
+		}
+		if bothEmpty(ov.Field(n), nv.Field(n)) {
+			continue
+		}
+		o, v := ov.Field(n).Interface(), nv.Field(n).Interface()
+		if !reflect.DeepEqual(o, v) {
+			fields = append(fields, &FieldChange{Field: name, Old: o, New: v})
+		}
+	}
+	return fields
+}
+
+// bothEmpty returns true if a and b are both empty slices or maps, so that a
+// nil list and an empty one, which docker and a manifest read back from a
+// file may disagree on, aren't reported as a change.
+func bothEmpty(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		return a.Len() == 0 && b.Len() == 0
+	}
+	return false
+}
+
+// Empty returns true if there are no differences.
+func (d *Diff) Empty() bool {
+	return len(d.AddedImages) == 0 && len(d.RemovedImages) == 0 && len(d.ChangedImages) == 0 &&
+		len(d.AddedContainers) == 0 && len(d.RemovedContainers) == 0 && len(d.ChangedContainers) == 0
+}
+
+// String returns a human-readable listing of the differences, with + marking
+// added objects, - removed ones, and ~ changed ones.
+func (d *Diff) String() string {
+	var b strings.Builder
+	if len(d.AddedImages)+len(d.RemovedImages)+len(d.ChangedImages) > 0 {
+		b.WriteString("images:\n")
+		for _, i := range d.AddedImages {
+			fmt.Fprintf(&b, "  + %s %s\n", i.ID, strings.Join(i.RepoTags, ","))
+		}
+		for _, i := range d.RemovedImages {
+			fmt.Fprintf(&b, "  - %s %s\n", i.ID, strings.Join(i.RepoTags, ","))
+		}
+		writeChanges(&b, d.ChangedImages)
+	}
+	if len(d.AddedContainers)+len(d.RemovedContainers)+len(d.ChangedContainers) > 0 {
+		b.WriteString("containers:\n")
+		for _, c := range d.AddedContainers {
+			fmt.Fprintf(&b, "  + %s %s\n", c.ID, strings.Join(c.Names, ","))
+		}
+		for _, c := range d.RemovedContainers {
+			fmt.Fprintf(&b, "  - %s %s\n", c.ID, strings.Join(c.Names, ","))
+		}
+		writeChanges(&b, d.ChangedContainers)
+	}
+	return b.String()
+}
+
+// writeChanges writes each of changes to b, one field per line.
+func writeChanges(b *strings.Builder, changes []*Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].ID != changes[j].ID {
+			return changes[i].ID < changes[j].ID
+		}
+		return changes[i].SourceURI < changes[j].SourceURI
+	})
+	for _, c := range changes {
+		if c.SourceURI != "" {
+			fmt.Fprintf(b, "  ~ %s on %s\n", c.ID, c.SourceURI)
+		} else {
+			fmt.Fprintf(b, "  ~ %s\n", c.ID)
+		}
+		for _, f := range c.Fields {
+			fmt.Fprintf(b, "      %s: %v -> %v\n", f.Field, f.Old, f.New)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package fester
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseIgnore(t *testing.T) {
+	tests := []struct {
+		fields  []string
+		want    Ignore
+		wantErr bool
+	}{
+		{fields: nil, want: Ignore{}},
+		{fields: []string{"container.State", "image.Size"}, want: Ignore{"container": {"State": true}, "image": {"Size": true}}},
+		{fields: []string{"container.state", "IMAGE.repotags"}, want: Ignore{"container": {"State": true}, "image": {"RepoTags": true}}},
+		{fields: []string{"container.Id"}, want: Ignore{"container": {"ID": true}}},
+		{
+			fields: []string{"date", "host", "timings", "container.State"},
+			want:   Ignore{"manifest": {"Date": true, "Hostname": true, "Timings": true}, "container": {"State": true}},
+		},
+		{fields: []string{"docker_api_version", "Hostname"}, want: Ignore{"manifest": {"DockerAPIVersion": true, "Hostname": true}}},
+		{fields: []string{"nonsense"}, wantErr: true},
+		{fields: []string{"volume.Name"}, wantErr: true},
+		{fields: []string{"container.Nonsense"}, wantErr: true},
+		{fields: []string{"image."}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIgnore(tt.fields)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseIgnore(%q) succeeded, want an error", tt.fields)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseIgnore(%q): %s", tt.fields, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIgnore(%q) = %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestCompareIgnoring(t *testing.T) {
+	before := &OutputMap{
+		Hostname: "a",
+		Date:     NewTimestamp(time.Unix(0, 0), time.RFC3339, true),
+		Images:   testImages(),
+		Containers: []*Container{
+			{ID: "c1", Names: []string{"/app"}, State: "running", Command: "serve --v1"},
+		},
+	}
+	after := &OutputMap{
+		Hostname: "b",
+		Date:     NewTimestamp(time.Unix(3600, 0), time.RFC3339, true),
+		Timings:  map[string]float64{"total": 1},
+		Images:   testImages(),
+		Containers: []*Container{
+			{ID: "c1", Names: []string{"/app"}, State: "running", Command: "serve --v2"},
+		},
+	}
+	after.Images[1].Size = 60
+	tests := []struct {
+		ignore []string
+		images int
+		ctrs   int
+	}{
+		{nil, 1, 1},
+		{[]string{"date", "host", "timings"}, 1, 1},
+		{[]string{"date", "container.command"}, 1, 0},
+		{[]string{"image.Size", "container.Command"}, 0, 0},
+	}
+	for _, tt := range tests {
+		ignore, err := ParseIgnore(tt.ignore)
+		if err != nil {
+			t.Fatalf("ParseIgnore(%q): %s", tt.ignore, err)
+		}
+		d := CompareIgnoring(before, after, ignore)
+		if len(d.ChangedImages) != tt.images || len(d.ChangedContainers) != tt.ctrs {
+			t.Errorf("ignoring %q: %d changed images and %d changed containers, want %d and %d", tt.ignore, len(d.ChangedImages), len(d.ChangedContainers), tt.images, tt.ctrs)
+		}
+		if len(d.AddedImages)+len(d.RemovedImages)+len(d.AddedContainers)+len(d.RemovedContainers) != 0 {
+			t.Errorf("ignoring %q: reported added or removed objects: %v", tt.ignore, d)
+		}
+	}
+}
+
+func TestCompareAggregated(t *testing.T) {
+	// Both hosts have the same image, used by a container on only one of
+	// them.
+	manifest := func(size int64) *OutputMap {
+		return &OutputMap{
+			Sources: []*Source{{URI: "tcp://a:2376"}, {URI: "tcp://b:2376"}},
+			Images: []*Image{
+				{ID: "sha256:aaa", RepoTags: []string{"app:1.0"}, InUse: true, SourceURI: "tcp://a:2376"},
+				{ID: "sha256:aaa", RepoTags: []string{"app:1.0"}, Size: size, SourceURI: "tcp://b:2376"},
+			},
+			Containers: []*Container{
+				{ID: "c1", ImageID: "sha256:aaa", State: "running", SourceURI: "tcp://a:2376"},
+			},
+		}
+	}
+	if d := Compare(manifest(0), manifest(0)); !d.Empty() {
+		t.Errorf("identical aggregated manifests differ:\n%s", d)
+	}
+	d := Compare(manifest(0), manifest(50))
+	if len(d.ChangedImages) != 1 || d.ChangedImages[0].SourceURI != "tcp://b:2376" || len(d.ChangedImages[0].Fields) != 1 || d.ChangedImages[0].Fields[0].Field != "Size" {
+		t.Errorf("a change on one host = %s", d)
+	}
+	if len(d.AddedImages)+len(d.RemovedImages) != 0 {
+		t.Errorf("images added or removed:\n%s", d)
+	}
+	after := manifest(0)
+	after.Images = after.Images[:1]
+	d = Compare(manifest(0), after)
+	if len(d.RemovedImages) != 1 || d.RemovedImages[0].SourceURI != "tcp://b:2376" || len(d.ChangedImages) != 0 {
+		t.Errorf("an image removed from one host = %s", d)
+	}
+}
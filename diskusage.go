@@ -0,0 +1,108 @@
+package fester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiskUsage is the disk space used by one type of Docker object, as reported
+// by docker system df. docker only reports sizes in human-readable form, so
+// SizeBytes and ReclaimableBytes are parsed from them and are only as precise
+// as the sizes docker prints.
+type DiskUsage struct {
+	Type             string `json:"Type" yaml:"Type"`
+	TotalCount       int    `json:"TotalCount" yaml:"TotalCount"`
+	Active           int    `json:"Active" yaml:"Active"`
+	Size             string `json:"Size" yaml:"Size"`
+	SizeBytes        int64  `json:"SizeBytes" yaml:"SizeBytes"`
+	Reclaimable      string `json:"Reclaimable" yaml:"Reclaimable"`
+	ReclaimableBytes int64  `json:"ReclaimableBytes" yaml:"ReclaimableBytes"`
+	SourceURI        string `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// diskUsageLine is a line of docker system df output.
+type diskUsageLine struct {
+	Type        string
+	TotalCount  string
+	Active      string
+	Size        string
+	Reclaimable string
+}
+
+// sizeUnits are the units docker uses for human-readable sizes: decimal ones
+// for disk usage and binary ones for memory.
+var sizeUnits = []struct {
+	suffix string
+	factor float64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"PiB", 1 << 50},
+	{"kB", 1e3},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"PB", 1e15},
+	{"B", 1},
+}
+
+// ParseSize parses a human-readable size printed by docker, such as "1.2GB" or
+// "512MiB", into a number of bytes. Anything after the size, such as the percentage in
+// "1.2GB (50%)", is ignored.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if n := strings.Index(s, " "); n >= 0 {
+		s = s[:n]
+	}
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			f, err := strconv.ParseFloat(strings.TrimSuffix(s, u.suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("parsing size %q: %s", s, err)
+			}
+			return int64(f * u.factor), nil
+		}
+	}
+	return 0, fmt.Errorf("parsing size %q: unknown unit", s)
+}
+
+// DiskUsage returns the disk space used by images, containers, volumes, and
+// the build cache.
+func (c *Client) DiskUsage(ctx context.Context) ([]*DiskUsage, error) {
+	stdout, stderr, err := c.Output(ctx, "system", "df", "--format", "{{json .}}")
+	if err != nil {
+		return nil, err
+	}
+	logWarnings(stderr)
+	usage := []*DiskUsage{}
+	for _, line := range ReadLines(stdout) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var l diskUsageLine
+		if err = json.Unmarshal([]byte(line), &l); err != nil {
+			return nil, err
+		}
+		u := &DiskUsage{
+			Type:        l.Type,
+			Size:        l.Size,
+			Reclaimable: l.Reclaimable,
+		}
+		u.TotalCount, _ = strconv.Atoi(l.TotalCount)
+		u.Active, _ = strconv.Atoi(l.Active)
+		if u.SizeBytes, err = ParseSize(l.Size); err != nil {
+			return nil, err
+		}
+		if u.ReclaimableBytes, err = ParseSize(l.Reclaimable); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
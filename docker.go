@@ -0,0 +1,395 @@
+package fester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Docker is the set of Docker operations Collect depends on. *Client
+// implements it by running the docker command; other implementations can be
+// used to collect from somewhere else or to stand in for a real daemon.
+type Docker interface {
+	APIVersion(ctx context.Context) (string, error)
+	Pull(ctx context.Context, image string) error
+	Version(ctx context.Context, image string) (*VersionInfo, error)
+	ListImages(ctx context.Context, filters Filters) ([]*Image, error)
+	InspectImage(ctx context.Context, ref string) (*Image, error)
+	ImageHistory(ctx context.Context, id string) ([]*HistoryItem, error)
+	ImagePlatforms(ctx context.Context) (map[string][]*ImagePlatform, error)
+	ListContainers(ctx context.Context, filters Filters) ([]*Container, error)
+	InspectContainer(ctx context.Context, id string) (*ContainerDetails, error)
+	ContainerStats(ctx context.Context, id string) (*ContainerStats, error)
+	ContainerLogs(ctx context.Context, id string, tail int) (string, error)
+	ListVolumes(ctx context.Context) ([]*Volume, error)
+	ListNetworks(ctx context.Context) ([]*Network, error)
+	ListPlugins(ctx context.Context) ([]*Plugin, error)
+	SystemInfo(ctx context.Context) (*System, error)
+	DiskUsage(ctx context.Context) ([]*DiskUsage, error)
+	Events(ctx context.Context, filters Filters, handle func(*Event)) error
+	SwarmManager(ctx context.Context) (bool, error)
+	ListServices(ctx context.Context) ([]*Service, error)
+	ListTasks(ctx context.Context, services []*Service) ([]*Task, error)
+}
+
+// Filters narrows down which objects docker lists. Each key is a docker
+// filter name, such as "reference", with the values to filter on. docker ORs
+// together multiple values for the same key, except for labels, which must all
+// match.
+type Filters map[string][]string
+
+// Args returns the filters as docker --filter options.
+func (f Filters) Args() []string {
+	var keys []string
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var args []string
+	for _, k := range keys {
+		for _, v := range f[k] {
+			args = append(args, "--filter", k+"="+v)
+		}
+	}
+	return args
+}
+
+// Client runs docker commands against a Docker daemon. A Client holds no
+// connections of its own, since each call runs a docker command that connects
+// and disconnects, so it can be reused for as long as needed. Wrap it in a
+// Reconnecting to have it created again after it fails to connect.
+type Client struct {
+	// Args are passed to every docker command before the subcommand.
+	Args []string
+	// Env is added to the environment of every docker command.
+	Env []string
+	// Progress is where the progress of pulls is written. It defaults to
+	// stderr.
+	Progress io.Writer
+}
+
+// NewClient returns a *Client that connects to the daemon at host using the
+// given API version and TLS options. An empty host uses docker's default, and
+// an apiVersion of "auto" or "" lets docker negotiate the version.
+func NewClient(host, apiVersion string, tlsArgs []string) *Client {
+	c := &Client{}
+	if host != "" {
+		c.Args = append(c.Args, "--host", host)
+	}
+	c.Args = append(c.Args, tlsArgs...)
+	if apiVersion != "" && apiVersion != "auto" {
+		c.Env = append(c.Env, "DOCKER_API_VERSION="+strings.TrimPrefix(apiVersion, "v"))
+	}
+	return c
+}
+
+// TLSArgs validates the client certificate, key, and CA certificate and returns
+// the docker options needed to connect to a daemon with mutual TLS. If none of
+// the paths are set no options are returned.
+func TLSArgs(cert, key, ca string) ([]string, error) {
+	if cert == "" && key == "" && ca == "" {
+		return nil, nil
+	}
+	if cert == "" || key == "" || ca == "" {
+		return nil, errors.New("the TLS certificate, key, and CA certificate must be set together")
+	}
+	if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+		return nil, fmt.Errorf("loading client certificate %s and key %s: %s", cert, key, err)
+	}
+	caPEM, err := ioutil.ReadFile(ca)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA certificate: %s", err)
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no PEM certificates found in CA certificate %s", ca)
+	}
+	args := []string{
+		"--tlsverify",
+		"--tlscert", cert,
+		"--tlskey", key,
+		"--tlscacert", ca,
+	}
+	return args, nil
+}
+
+// InsecureTLSArgs returns the docker options needed to connect to a daemon
+// over TLS without verifying its certificate, for example a test daemon with a
+// self-signed one. The client certificate and key are sent if they are set,
+// and are validated like TLSArgs does. This makes the connection open to
+// interception, so it should never be used in production.
+func InsecureTLSArgs(cert, key string) ([]string, error) {
+	args := []string{"--tls", "--tlsverify=false"}
+	if cert == "" && key == "" {
+		return args, nil
+	}
+	if cert == "" || key == "" {
+		return nil, errors.New("the TLS certificate and key must be set together")
+	}
+	if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+		return nil, fmt.Errorf("loading client certificate %s and key %s: %s", cert, key, err)
+	}
+	return append(args, "--tlscert", cert, "--tlskey", key), nil
+}
+
+// Command returns an *exec.Cmd that runs docker with args against the
+// client's daemon.
+func (c *Client) Command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", append(append([]string{}, c.Args...), args...)...)
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	return cmd
+}
+
+// Output runs docker with the given arguments and returns whatever it wrote
+// to stdout and stderr. If the command fails the error includes its stderr.
+func (c *Client) Output(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	var outbuf, errbuf bytes.Buffer
+	cmd := c.Command(ctx, args...)
+	cmd.Stdout = &outbuf
+	cmd.Stderr = &errbuf
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(errbuf.String()); msg != "" {
+			err = fmt.Errorf("%s: %s", err, msg)
+		}
+		return nil, nil, err
+	}
+	return outbuf.Bytes(), errbuf.Bytes(), nil
+}
+
+// APIVersion returns the Docker API version used to talk to the daemon. When
+// the version is negotiated this is the version the daemon agreed to.
+func (c *Client) APIVersion(ctx context.Context) (string, error) {
+	stdout, _, err := c.Output(ctx, "version", "--format", "{{.Client.APIVersion}}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// Pull pulls a Docker image. Progress is written to c.Progress.
+func (c *Client) Pull(ctx context.Context, image string) error {
+	cmd := c.Command(
+		ctx,
+		"pull",
+		image,
+	)
+	var progress io.Writer = os.Stderr
+	if c.Progress != nil {
+		progress = c.Progress
+	}
+	var errbuf bytes.Buffer
+	cmd.Stdout = progress
+	cmd.Stderr = io.MultiWriter(progress, &errbuf)
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(errbuf.String()); msg != "" {
+			err = fmt.Errorf("%s: %s", err, msg)
+		}
+	}
+	return err
+}
+
+// ImageID returns the image identifier for the docker image.
+func (c *Client) ImageID(ctx context.Context, image string) (string, error) {
+	stdout, _, err := c.Output(
+		ctx,
+		"inspect",
+		"--format",
+		"{{.Id}}",
+		image,
+	)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// Version returns the output of calling --version on the image.
+func (c *Client) Version(ctx context.Context, image string) (*VersionInfo, error) {
+	stdout, _, err := c.Output(
+		ctx,
+		"run",
+		"--rm",
+		image,
+		"--version",
+	)
+	if err != nil {
+		return nil, err
+	}
+	imageID, err := c.ImageID(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return NewVersionInfo(ReadLines(stdout), imageID), nil
+}
+
+// inspect runs the ls subcommand of object with lsArgs to get a list of IDs,
+// then decodes the result of inspecting them into v. IDs listed more than once
+// are only inspected once, and v is left alone if nothing is listed. Warnings
+// reported by docker are logged rather than treated as errors.
+func (c *Client) inspect(ctx context.Context, object string, v interface{}, lsArgs ...string) error {
+	stdout, stderr, err := c.Output(ctx, append([]string{object, "ls", "--quiet"}, lsArgs...)...)
+	if err != nil {
+		return err
+	}
+	logWarnings(stderr)
+	seen := make(map[string]bool)
+	args := []string{object, "inspect"}
+	for _, id := range ReadLines(stdout) {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			args = append(args, id)
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	return c.inspectJSON(ctx, v, args...)
+}
+
+// inspectJSON runs docker with args and unmarshals its output into v.
+func (c *Client) inspectJSON(ctx context.Context, v interface{}, args ...string) error {
+	stdout, stderr, err := c.Output(ctx, args...)
+	if err != nil {
+		return err
+	}
+	logWarnings(stderr)
+	return json.Unmarshal(stdout, v)
+}
+
+// apiGet makes a GET request for path, which includes the API version, such
+// as /v1.47/images/json, straight to the daemon's API and unmarshals the
+// response into v. It's for what the docker command doesn't expose. The
+// request goes through docker system dial-stdio, so it connects to the daemon
+// the same way every other command does.
+func (c *Client) apiGet(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Close = true
+	var reqbuf, outbuf, errbuf bytes.Buffer
+	if err = req.Write(&reqbuf); err != nil {
+		return err
+	}
+	cmd := c.Command(ctx, "system", "dial-stdio")
+	cmd.Stdin = &reqbuf
+	cmd.Stdout = &outbuf
+	cmd.Stderr = &errbuf
+	if err = cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(errbuf.String()); msg != "" {
+			err = fmt.Errorf("%s: %s", err, msg)
+		}
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(&outbuf), req)
+	if err != nil {
+		return fmt.Errorf("reading the response to GET %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading the response to GET %s: %s", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct{ Message string }
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("GET %s returned %s: %s", path, resp.Status, apiErr.Message)
+		}
+		return fmt.Errorf("GET %s returned %s", path, resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// logWarnings logs each non-empty line of a docker command's stderr.
+func logWarnings(stderr []byte) {
+	for _, line := range ReadLines(stderr) {
+		if line = strings.TrimSpace(line); line != "" {
+			slog.Warn("warning from docker", "message", line)
+		}
+	}
+}
+
+// transientErrors are fragments of docker error messages that indicate the
+// daemon couldn't be reached, as opposed to a request it rejected.
+var transientErrors = []string{
+	"cannot connect to the docker daemon",
+	"is the docker daemon running",
+	"connection refused",
+	"connection reset",
+	"connect: no such file or directory",
+	"i/o timeout",
+	"tls handshake timeout",
+	"service unavailable",
+	"unexpected eof",
+}
+
+// IsTransient returns true if err looks like a connection-level failure that
+// may go away if the call is retried.
+func IsTransient(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, t := range transientErrors {
+		if strings.Contains(msg, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// deniedErrors are substrings of the errors docker reports when the daemon
+// refuses a call, with a 403 because an authorization plugin or proxy forbids
+// it, or a 501 because it doesn't implement it.
+var deniedErrors = []string{
+	"authorization denied",
+	"forbidden",
+	"status code 403",
+	"not implemented",
+	"status code 501",
+}
+
+// IsDenied returns true if err looks like the daemon refused the call, rather
+// than it failing to get there. Retrying such a call won't help.
+func IsDenied(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, d := range deniedErrors {
+		if strings.Contains(msg, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retry calls f until it succeeds, returns an error that isn't transient, or
+// has been retried the given number of times. The wait between attempts
+// starts at interval and doubles after each retry. The last error is returned,
+// or the context's error if it is done before f succeeds.
+func Retry(ctx context.Context, what string, retries int, interval time.Duration, f func() error) error {
+	err := f()
+	for attempt := 1; err != nil && attempt <= retries && IsTransient(err); attempt++ {
+		slog.Warn("retrying Docker call", "call", what, "attempt", attempt, "retries", retries, "wait", interval, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+		err = f()
+	}
+	return err
+}
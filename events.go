@@ -0,0 +1,61 @@
+package fester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Event is an event reported by the Docker daemon, such as a container being
+// created.
+type Event struct {
+	Type   string
+	Action string
+	ID     string `json:"id"`
+	Time   int64  `json:"time"`
+}
+
+// WatchFilters are the events that change what a manifest lists: containers
+// being created or destroyed, and images being pulled, loaded, tagged, or
+// deleted.
+var WatchFilters = Filters{
+	"type":  {"container", "image"},
+	"event": {"create", "destroy", "pull", "import", "load", "tag", "untag", "delete"},
+}
+
+// Events streams the daemon's events that match filters, calling handle for
+// each one, until ctx is done or the stream fails. It always returns an
+// error, since the stream only ends if something went wrong.
+func (c *Client) Events(ctx context.Context, filters Filters, handle func(*Event)) error {
+	cmd := c.Command(ctx, append([]string{"events", "--format", "{{json .}}"}, filters.Args()...)...)
+	var errbuf bytes.Buffer
+	cmd.Stderr = &errbuf
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		e := &Event{}
+		if err = json.Unmarshal(scanner.Bytes(), e); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return fmt.Errorf("parsing event: %s", err)
+		}
+		handle(e)
+	}
+	if err = cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(errbuf.String()); msg != "" {
+			err = fmt.Errorf("%s: %s", err, msg)
+		}
+		return err
+	}
+	return errors.New("the event stream was closed")
+}
@@ -0,0 +1,54 @@
+package fester
+
+import (
+	"path"
+	"strings"
+)
+
+// ExcludeImages returns the images that don't have a tag matching one of
+// patterns, along with how many were left out. Patterns are matched with
+// path.Match, so * doesn't match a /.
+func ExcludeImages(images []*Image, patterns []string) ([]*Image, int) {
+	if len(patterns) == 0 {
+		return images, 0
+	}
+	kept := []*Image{}
+	for _, i := range images {
+		if !matchAny(patterns, i.RepoTags) {
+			kept = append(kept, i)
+		}
+	}
+	return kept, len(images) - len(kept)
+}
+
+// ExcludeContainers returns the containers that don't have a name matching
+// one of patterns, along with how many were left out. Names are matched both
+// with and without docker's leading /.
+func ExcludeContainers(containers []*Container, patterns []string) ([]*Container, int) {
+	if len(patterns) == 0 {
+		return containers, 0
+	}
+	kept := []*Container{}
+	for _, c := range containers {
+		names := append([]string{}, c.Names...)
+		for _, name := range c.Names {
+			names = append(names, strings.TrimPrefix(name, "/"))
+		}
+		if !matchAny(patterns, names) {
+			kept = append(kept, c)
+		}
+	}
+	return kept, len(containers) - len(kept)
+}
+
+// matchAny returns true if any of values matches any of patterns.
+func matchAny(patterns, values []string) bool {
+	for _, p := range patterns {
+		for _, v := range values {
+			if ok, _ := path.Match(p, v); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
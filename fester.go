@@ -0,0 +1,855 @@
+// Package fester collects information about Docker images, the Docker daemon
+// they run on, and a set of files into a single manifest.
+package fester
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// SchemaVersion is the version of the manifest's layout. It is bumped whenever
+// the output changes in a way that could break consumers, such as removing or
+// renaming a field. schema.json must be updated along with it.
+const SchemaVersion = 1
+
+// Options controls what Collect gathers.
+type Options struct {
+	// Registry and Tag are used to build the full name of each of Images.
+	Registry string
+	Tag      string
+	// Images are the names of the images to pull and get version info from.
+	// It may be empty, in which case nothing is pulled.
+	Images []string
+	// ImageFilters narrows down which images on the host are listed.
+	ImageFilters Filters
+	// ImageRegistries, if set, lists only the images from these registry
+	// hosts, as parsed by ParseReference.
+	ImageRegistries []string
+	// ImageCreatedBefore and ImageCreatedAfter, if set, list only the
+	// images created before and after them. They apply after the filters.
+	ImageCreatedBefore time.Time
+	ImageCreatedAfter  time.Time
+	// RawTags keeps the RepoTags and RepoDigests of images exactly as docker
+	// reports them, rather than normalizing them with NormalizeTags.
+	RawTags bool
+	// ContainerFilters narrows down which containers on the host are listed.
+	ContainerFilters Filters
+	// ExcludeImages and ExcludeContainers leave out the images with a tag,
+	// and the containers with a name, matching one of the patterns. They
+	// apply after the filters.
+	ExcludeImages     []string
+	ExcludeContainers []string
+	// RunningOnly lists only the running containers, and only the images
+	// they were started from.
+	RunningOnly bool
+	// Files are the paths of the files to include in the manifest, or glob
+	// patterns matching them, as expanded by ExpandFiles.
+	Files []string
+	// EmbedMaxSize, if more than zero, embeds the contents of each of Files
+	// that is no bigger than this many bytes in the manifest.
+	EmbedMaxSize int64
+	// SkipSystemInfo leaves out info about the Docker daemon and its host.
+	SkipSystemInfo bool
+	// SkipImages and SkipContainers don't list the images or the containers,
+	// leaving Images or Containers nil, so that they're left out of the
+	// manifest altogether. Which images are InUse isn't known without the
+	// containers, so none are marked and the summary counts none as unused.
+	SkipImages     bool
+	SkipContainers bool
+	// InspectContainers inspects each container individually to include its
+	// environment, command, entrypoint, and mounts. RedactEnv lists substrings of the
+	// names of environment variables whose values are replaced with ***.
+	InspectContainers bool
+	RedactEnv         []string
+	// RestartThreshold is the number of restarts above which an inspected
+	// container is listed in the summary's RestartedContainers.
+	RestartThreshold int
+	// ImageHistory includes the build history of each image. Each image's
+	// history is given up on after HistoryTimeout, if it is set.
+	ImageHistory   bool
+	HistoryTimeout time.Duration
+	// Stats includes a sample of the CPU and memory each running container is
+	// using. Each container's sample is given up on after StatsTimeout, if it
+	// is set.
+	Stats        bool
+	StatsTimeout time.Duration
+	// ContainerLogs, if more than zero, includes the last ContainerLogs
+	// lines each container logged. No more than LogsMaxBytes of logs are kept
+	// across all the containers, if it is more than zero.
+	ContainerLogs int
+	LogsMaxBytes  int64
+	// DiskUsage includes the disk space used by each type of Docker object.
+	DiskUsage bool
+	// Plugins includes the plugins installed on the daemon.
+	Plugins bool
+	// Hostname is recorded as the manifest's hostname. It defaults to what
+	// Hostname returns.
+	Hostname string
+	// Labels are recorded in the manifest as they are, to describe the host
+	// in ways Docker can't, such as its environment or region.
+	Labels map[string]string
+	// PromoteLabels are container label keys whose values are added to the
+	// manifest's Labels, as PromoteLabels does, from the containers that
+	// carry them.
+	PromoteLabels []string
+	// Swarm includes the services and tasks of the Swarm when the daemon is a
+	// Swarm manager.
+	Swarm bool
+	// Sort sorts images by their first tag and then ID, and containers by
+	// their first name and then ID, rather than leaving them in the order
+	// docker lists them in.
+	Sort bool
+	// DateFormat is the Go time layout the manifest's date is formatted with,
+	// or EpochFormat for a Unix timestamp. It defaults to time.RFC3339. The
+	// date is in local time unless UTC is set.
+	DateFormat string
+	UTC        bool
+	// Progress, if set, is called after each per-object Docker call, such as
+	// a container inspection, with what is being done and how many of the
+	// total objects are done. It may be called from several goroutines at
+	// once.
+	Progress func(what string, done, total int)
+	// MaxConcurrency caps the number of per-object Docker calls, such as
+	// container inspections and image histories, made at once. It defaults
+	// to DefaultMaxConcurrency.
+	MaxConcurrency int
+	// Strict fails the collection if any listing fails. Otherwise the
+	// optional ones, volumes, system info, disk usage, and the Swarm, are left
+	// out if the daemon refuses them, and networks and plugins are left out
+	// if they fail for any reason other than ctx being done.
+	Strict bool
+	// SectionTimeouts gives some of the sections, keyed by their Section
+	// names, a time limit of their own. A section that runs out of time is
+	// left out, or left with what was collected in time, and named in the
+	// manifest's TimedOut, unless Strict is set, in which case it fails the
+	// collection.
+	SectionTimeouts map[string]time.Duration
+	// Retries and RetryInterval control how Docker calls that fail with a
+	// transient error are retried.
+	Retries       int
+	RetryInterval time.Duration
+}
+
+// The names of the sections that can be given a timeout of their own in
+// Options.SectionTimeouts.
+const (
+	SectionSystemInfo    = "system_info"
+	SectionDiskUsage     = "disk_usage"
+	SectionPlugins       = "plugins"
+	SectionSwarm         = "swarm"
+	SectionStats         = "stats"
+	SectionContainerLogs = "container_logs"
+	SectionImageHistory  = "image_history"
+)
+
+// Sections lists the sections that can be given a timeout of their own.
+var Sections = []string{SectionSystemInfo, SectionDiskUsage, SectionPlugins, SectionSwarm, SectionStats, SectionContainerLogs, SectionImageHistory}
+
+// OutputMap contains the info that is written out to a file. Its Hostname
+// names the host fester ran on, even when it is aggregated from several
+// daemons, which are listed in Sources instead.
+type OutputMap struct {
+	SchemaVersion    int               `json:"schema_version" yaml:"schema_version"`
+	FesterVersion    string            `json:"fester_version" yaml:"fester_version"`
+	Hostname         string            `json:"hostname" yaml:"hostname"`
+	Date             Timestamp         `json:"date" yaml:"date"`
+	Labels           map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	DockerAPIVersion string            `json:"docker_api_version" yaml:"docker_api_version"`
+	Sources          []*Source         `json:"sources,omitempty" yaml:"sources,omitempty"`
+	// TimedOut names the sections that ran out of time, and so are missing
+	// or incomplete. A missing system, disk_usage, plugins, services, or
+	// tasks is written as a "timeout": true marker in its place.
+	TimedOut []string `json:"timed_out,omitempty" yaml:"timed_out,omitempty"`
+	// Errors describes the listings that failed, and so are missing, when
+	// only some of the manifest could be collected.
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+	// Timings gives how long each section took to collect, in seconds of
+	// wall-clock time, with the whole collection under "total". Sections
+	// collected concurrently overlap, so they add up to more than the
+	// total. After an incremental update they still describe the last full
+	// collection.
+	Timings      map[string]float64        `json:"timings,omitempty" yaml:"timings,omitempty"`
+	System       *System                   `json:"system,omitempty" yaml:"system,omitempty"`
+	DiskUsage    []*DiskUsage              `json:"disk_usage,omitempty" yaml:"disk_usage,omitempty"`
+	Files        []*FileEntry              `json:"files" yaml:"files"`
+	Summary      *Summary                  `json:"summary" yaml:"summary"`
+	DockerImages map[string][]*VersionInfo `json:"docker_images" yaml:"docker_images"`
+	// Images, Containers, and Volumes are nil if they weren't collected, and
+	// are then left out rather than written as empty lists.
+	Images     []*Image                `json:"images,omitzero" yaml:"images"`
+	Containers []*Container            `json:"containers,omitzero" yaml:"containers"`
+	Projects   map[string][]*Container `json:"projects,omitempty" yaml:"projects,omitempty"`
+	Volumes    []*Volume               `json:"volumes,omitzero" yaml:"volumes"`
+	Networks   []*Network              `json:"networks" yaml:"networks"`
+	Plugins    []*Plugin               `json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	Services   []*Service              `json:"services,omitempty" yaml:"services,omitempty"`
+	Tasks      []*Task                 `json:"tasks,omitempty" yaml:"tasks,omitempty"`
+}
+
+// Collect gathers the manifest described by opts using cli. The Docker calls
+// stop when ctx is done.
+func Collect(ctx context.Context, cli Docker, opts Options) (*OutputMap, error) {
+	collectStart := time.Now()
+	// timed records how long the named section took, since start.
+	var (
+		timings   = map[string]float64{}
+		timingsMu sync.Mutex
+	)
+	timed := func(name string, start time.Time) {
+		timingsMu.Lock()
+		timings[name] = seconds(time.Since(start))
+		timingsMu.Unlock()
+	}
+	if opts.DateFormat == "" {
+		opts.DateFormat = time.RFC3339
+	}
+	retry := func(ctx context.Context, what string, f func() error) error {
+		return Retry(ctx, what, opts.Retries, opts.RetryInterval, f)
+	}
+	var apiVersion string
+	err := retry(ctx, "connecting to Docker", func() (err error) {
+		apiVersion, err = cli.APIVersion(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting Docker API version: %s", err)
+	}
+	if opts.RunningOnly {
+		filters := Filters{}
+		for k, v := range opts.ContainerFilters {
+			filters[k] = v
+		}
+		filters["status"] = []string{"running"}
+		opts.ContainerFilters = filters
+	}
+	start := time.Now()
+	fileEntries, err := ReadFiles(opts.Files, opts.EmbedMaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("reading files: %s", err)
+	}
+	if len(opts.Files) > 0 {
+		timed("files", start)
+	}
+	start = time.Now()
+	imageVersions := make(map[string][]*VersionInfo)
+	for _, image := range opts.Images {
+		spec := New(opts.Registry, image, opts.Tag)
+		slog.Info("pulling image", "image", spec.String())
+		err = retry(ctx, "pulling image", func() error {
+			return cli.Pull(ctx, spec.String())
+		})
+		if err != nil {
+			return nil, fmt.Errorf("pulling image: %s", err)
+		}
+		var v *VersionInfo
+		err = retry(ctx, "getting version", func() (err error) {
+			v, err = cli.Version(ctx, spec.String())
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getting version: %s", err)
+		}
+		imageVersions[spec.String()] = append(imageVersions[spec.String()], v)
+	}
+	if len(opts.Images) > 0 {
+		timed("pulls", start)
+	}
+	// The listings don't depend on each other, so they run concurrently. Each
+	// one fills in its own variable, so the output doesn't depend on which
+	// finishes first. Unless opts.Strict is set, a listing that fails is
+	// recorded in the manifest's Errors and the rest carry on; otherwise the
+	// first error cancels the rest.
+	g, gctx := errgroup.WithContext(ctx)
+	// denied returns true if an optional listing failed because the daemon
+	// refused it, in which case it's left out rather than failing the
+	// collection, unless opts.Strict is set.
+	denied := func(what string, err error) bool {
+		if err == nil || opts.Strict || gctx.Err() != nil || !IsDenied(err) {
+			return false
+		}
+		slog.Warn("the daemon refused "+what+", leaving them out", "error", err)
+		return true
+	}
+	// failed returns true if a listing failed, in which case the error is
+	// recorded in errs and the manifest is collected without that section,
+	// unless opts.Strict is set or the collection was stopped.
+	var (
+		errs   []string
+		errsMu sync.Mutex
+	)
+	failed := func(what string, err error) bool {
+		if err == nil || opts.Strict || gctx.Err() != nil {
+			return false
+		}
+		slog.Error(what+" failed, leaving it out of the manifest", "error", err)
+		errsMu.Lock()
+		errs = append(errs, what+": "+err.Error())
+		errsMu.Unlock()
+		return true
+	}
+	// section returns the context for the named section, which is done
+	// after its timeout if it has one.
+	section := func(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+		if d := opts.SectionTimeouts[name]; d > 0 {
+			return context.WithTimeout(ctx, d)
+		}
+		return context.WithCancel(ctx)
+	}
+	// expired returns true if the named section failed because sctx, its
+	// context, ran out of time while parent didn't, in which case it's
+	// recorded in timedOut rather than failing the collection, unless
+	// opts.Strict is set.
+	var (
+		timedOut   []string
+		timedOutMu sync.Mutex
+	)
+	expired := func(parent, sctx context.Context, name string, err error) bool {
+		if err == nil || opts.Strict || parent.Err() != nil || sctx.Err() != context.DeadlineExceeded {
+			return false
+		}
+		slog.Warn("the "+name+" section timed out, leaving what was collected in time", "timeout", opts.SectionTimeouts[name], "error", err)
+		timedOutMu.Lock()
+		timedOut = append(timedOut, name)
+		timedOutMu.Unlock()
+		return true
+	}
+	var images []*Image
+	if !opts.SkipImages {
+		g.Go(func() error {
+			start := time.Now()
+			defer timed("images", start)
+			err := retry(gctx, "listing images", func() (err error) {
+				images, err = cli.ListImages(gctx, opts.ImageFilters)
+				return err
+			})
+			if failed("listing images", err) {
+				images = nil
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("listing images: %s", err)
+			}
+			logListed("images", len(images), start)
+			return nil
+		})
+	}
+	var containers []*Container
+	if !opts.SkipContainers {
+		g.Go(func() error {
+			start := time.Now()
+			defer timed("containers", start)
+			err := retry(gctx, "listing containers", func() (err error) {
+				containers, err = cli.ListContainers(gctx, opts.ContainerFilters)
+				return err
+			})
+			if failed("listing containers", err) {
+				containers = nil
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("listing containers: %s", err)
+			}
+			logListed("containers", len(containers), start)
+			return nil
+		})
+	}
+	var volumes []*Volume
+	g.Go(func() error {
+		start := time.Now()
+		defer timed("volumes", start)
+		err := retry(gctx, "listing volumes", func() (err error) {
+			volumes, err = cli.ListVolumes(gctx)
+			return err
+		})
+		if denied("listing volumes", err) {
+			volumes, err = []*Volume{}, nil
+		}
+		if failed("listing volumes", err) {
+			volumes = nil
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("listing volumes: %s", err)
+		}
+		logListed("volumes", len(volumes), start)
+		return nil
+	})
+	var networks []*Network
+	g.Go(func() error {
+		start := time.Now()
+		defer timed("networks", start)
+		err := retry(gctx, "listing networks", func() (err error) {
+			networks, err = cli.ListNetworks(gctx)
+			return err
+		})
+		if err != nil && (opts.Strict || gctx.Err() != nil) {
+			return fmt.Errorf("listing networks: %s", err)
+		}
+		if err != nil {
+			slog.Warn("listing networks failed, leaving them out", "error", err)
+			networks = []*Network{}
+		}
+		logListed("networks", len(networks), start)
+		return nil
+	})
+	var plugins []*Plugin
+	if opts.Plugins {
+		g.Go(func() error {
+			start := time.Now()
+			defer timed(SectionPlugins, start)
+			sctx, cancel := section(gctx, SectionPlugins)
+			defer cancel()
+			err := retry(sctx, "listing plugins", func() (err error) {
+				plugins, err = cli.ListPlugins(sctx)
+				return err
+			})
+			if expired(gctx, sctx, SectionPlugins, err) {
+				return nil
+			}
+			if err != nil && (opts.Strict || gctx.Err() != nil) {
+				return fmt.Errorf("listing plugins: %s", err)
+			}
+			if err != nil {
+				slog.Warn("listing plugins failed, leaving them out", "error", err)
+				plugins = []*Plugin{}
+			}
+			logListed("plugins", len(plugins), start)
+			return nil
+		})
+	}
+	var system *System
+	if !opts.SkipSystemInfo {
+		g.Go(func() error {
+			start := time.Now()
+			defer timed(SectionSystemInfo, start)
+			sctx, cancel := section(gctx, SectionSystemInfo)
+			defer cancel()
+			err := retry(sctx, "getting system info", func() (err error) {
+				system, err = cli.SystemInfo(sctx)
+				return err
+			})
+			if expired(gctx, sctx, SectionSystemInfo, err) || denied("getting system info", err) {
+				return nil
+			}
+			if failed("getting system info", err) {
+				system = nil
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("getting system info: %s", err)
+			}
+			slog.Debug("got system info", "duration", time.Since(start))
+			return nil
+		})
+	}
+	var diskUsage []*DiskUsage
+	if opts.DiskUsage {
+		g.Go(func() error {
+			start := time.Now()
+			defer timed(SectionDiskUsage, start)
+			sctx, cancel := section(gctx, SectionDiskUsage)
+			defer cancel()
+			err := retry(sctx, "getting disk usage", func() (err error) {
+				diskUsage, err = cli.DiskUsage(sctx)
+				return err
+			})
+			if expired(gctx, sctx, SectionDiskUsage, err) || denied("getting disk usage", err) {
+				return nil
+			}
+			if failed("getting disk usage", err) {
+				diskUsage = nil
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("getting disk usage: %s", err)
+			}
+			slog.Debug("got disk usage", "duration", time.Since(start))
+			return nil
+		})
+	}
+	var services []*Service
+	var tasks []*Task
+	if opts.Swarm {
+		g.Go(func() error {
+			start := time.Now()
+			defer timed(SectionSwarm, start)
+			sctx, cancel := section(gctx, SectionSwarm)
+			defer cancel()
+			var manager bool
+			err := retry(sctx, "checking for a Swarm manager", func() (err error) {
+				manager, err = cli.SwarmManager(sctx)
+				return err
+			})
+			if expired(gctx, sctx, SectionSwarm, err) || denied("checking for a Swarm manager", err) {
+				return nil
+			}
+			if failed("checking for a Swarm manager", err) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("checking for a Swarm manager: %s", err)
+			}
+			if !manager {
+				slog.Debug("not a Swarm manager, leaving out services")
+				return nil
+			}
+			err = retry(sctx, "listing services", func() (err error) {
+				services, err = cli.ListServices(sctx)
+				return err
+			})
+			if expired(gctx, sctx, SectionSwarm, err) || denied("listing services", err) {
+				services = nil
+				return nil
+			}
+			if failed("listing services", err) {
+				services = nil
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("listing services: %s", err)
+			}
+			err = retry(sctx, "listing tasks", func() (err error) {
+				tasks, err = cli.ListTasks(sctx, services)
+				return err
+			})
+			if expired(gctx, sctx, SectionSwarm, err) || denied("listing tasks", err) {
+				services, tasks = nil, nil
+				return nil
+			}
+			if failed("listing tasks", err) {
+				services, tasks = nil, nil
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("listing tasks: %s", err)
+			}
+			logListed("services", len(services), start)
+			return nil
+		})
+	}
+	if err = g.Wait(); err != nil {
+		return nil, err
+	}
+	if !opts.RawTags {
+		NormalizeTags(images)
+	}
+	// The images and containers are filtered before any of the per-object
+	// calls, so that none are made for those left out. Images are marked in
+	// use before the containers are excluded, since an excluded container
+	// still uses its image.
+	images = FilterRegistries(images, opts.ImageRegistries)
+	images = FilterCreated(images, opts.ImageCreatedBefore, opts.ImageCreatedAfter)
+	if opts.RunningOnly {
+		images = ImagesInUse(images, containers)
+	}
+	if !opts.SkipContainers {
+		MarkInUse(images, containers)
+	}
+	images, excludedImages := ExcludeImages(images, opts.ExcludeImages)
+	containers, excludedContainers := ExcludeContainers(containers, opts.ExcludeContainers)
+	if system != nil && system.ContainerdSnapshotter && len(images) > 0 {
+		start := time.Now()
+		var platforms map[string][]*ImagePlatform
+		err = retry(ctx, "listing image platforms", func() (err error) {
+			platforms, err = cli.ImagePlatforms(ctx)
+			return err
+		})
+		if err != nil && (opts.Strict || ctx.Err() != nil) {
+			return nil, fmt.Errorf("listing image platforms: %s", err)
+		}
+		if err != nil {
+			slog.Warn("listing image platforms failed, leaving them out", "error", err)
+		}
+		AddPlatforms(images, platforms)
+		timed("image_platforms", start)
+	}
+	// The per-object calls below share sem, so no more than
+	// opts.MaxConcurrency of them are ever made at once.
+	sem := newSemaphore(opts)
+	if opts.InspectContainers {
+		start := time.Now()
+		if err = inspectContainers(ctx, cli, sem, containers, opts); err != nil {
+			return nil, err
+		}
+		timed("inspect_containers", start)
+		logListed("container details", len(containers), start)
+	}
+	if opts.Stats {
+		start := time.Now()
+		sctx, cancel := section(ctx, SectionStats)
+		err = containerStats(sctx, cli, sem, containers, opts)
+		cancel()
+		timed(SectionStats, start)
+		if err != nil && !expired(ctx, sctx, SectionStats, err) {
+			return nil, err
+		}
+		logListed("container stats", len(containers), start)
+	}
+	if opts.ContainerLogs > 0 {
+		start := time.Now()
+		sctx, cancel := section(ctx, SectionContainerLogs)
+		err = containerLogs(sctx, cli, sem, containers, opts)
+		cancel()
+		timed(SectionContainerLogs, start)
+		if err != nil && !expired(ctx, sctx, SectionContainerLogs, err) {
+			return nil, err
+		}
+		logListed("container logs", len(containers), start)
+	}
+	if opts.ImageHistory {
+		start := time.Now()
+		sctx, cancel := section(ctx, SectionImageHistory)
+		err = imageHistories(sctx, cli, sem, images, opts)
+		cancel()
+		timed(SectionImageHistory, start)
+		if err != nil && !expired(ctx, sctx, SectionImageHistory, err) {
+			return nil, err
+		}
+		logListed("image histories", len(images), start)
+	}
+	if opts.SkipImages {
+		images = nil
+	}
+	if opts.SkipContainers {
+		containers = nil
+	}
+	if opts.Sort {
+		SortImages(images)
+		SortContainers(containers)
+	}
+	summary := NewSummary(images, containers)
+	for _, u := range diskUsage {
+		summary.TotalReclaimableBytes += u.ReclaimableBytes
+	}
+	summary.AddServices(services, tasks)
+	if opts.InspectContainers {
+		summary.AddRestarts(containers, opts.RestartThreshold)
+	}
+	summary.ExcludedImageCount = excludedImages
+	summary.ExcludedContainerCount = excludedContainers
+	hostname := opts.Hostname
+	if hostname == "" {
+		hostname = Hostname(ctx)
+	}
+	sort.Strings(timedOut)
+	sort.Strings(errs)
+	timed("total", collectStart)
+	output := &OutputMap{
+		SchemaVersion:    SchemaVersion,
+		FesterVersion:    Version,
+		Hostname:         hostname,
+		Date:             NewTimestamp(time.Now(), opts.DateFormat, opts.UTC),
+		Labels:           PromoteLabels(opts.Labels, containers, opts.PromoteLabels),
+		DockerAPIVersion: apiVersion,
+		TimedOut:         timedOut,
+		Errors:           errs,
+		Timings:          timings,
+		System:           system,
+		DiskUsage:        diskUsage,
+		Files:            fileEntries,
+		Summary:          summary,
+		DockerImages:     imageVersions,
+		Images:           images,
+		Containers:       containers,
+		Volumes:          volumes,
+		Networks:         networks,
+		Plugins:          plugins,
+		Services:         services,
+		Tasks:            tasks,
+	}
+	slog.Info("collected manifest", "images", len(images), "containers", len(containers), "duration", time.Since(collectStart))
+	return output, nil
+}
+
+// seconds returns d in seconds, to the millisecond.
+func seconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
+
+// DefaultMaxConcurrency is the number of per-object Docker calls, such as
+// container inspections, made at once when Options.MaxConcurrency isn't set.
+const DefaultMaxConcurrency = 8
+
+// newSemaphore returns the semaphore that caps the per-object calls made
+// with opts at opts.MaxConcurrency.
+func newSemaphore(opts Options) *semaphore.Weighted {
+	if opts.MaxConcurrency <= 0 {
+		return semaphore.NewWeighted(DefaultMaxConcurrency)
+	}
+	return semaphore.NewWeighted(int64(opts.MaxConcurrency))
+}
+
+// inspectContainers fills in the details of each of containers, inspecting as
+// many of them at once as sem allows. A container that can't be inspected,
+// for example because it was removed after it was listed, is logged and left
+// without details.
+func inspectContainers(ctx context.Context, cli Docker, sem *semaphore.Weighted, containers []*Container, opts Options) error {
+	g, gctx := errgroup.WithContext(ctx)
+	done := progress(opts, "inspecting containers", len(containers))
+	for _, c := range containers {
+		c := c
+		g.Go(func() error {
+			defer done()
+			if err := sem.Acquire(gctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+			var d *ContainerDetails
+			err := Retry(gctx, "inspecting container", opts.Retries, opts.RetryInterval, func() (err error) {
+				d, err = cli.InspectContainer(gctx, c.ID)
+				return err
+			})
+			if err != nil && gctx.Err() != nil {
+				return fmt.Errorf("inspecting container %s: %s", c.ID, err)
+			}
+			if err != nil {
+				slog.Warn("inspecting container failed, skipping it", "container", c.ID, "error", err)
+				return nil
+			}
+			d.Apply(c, opts.RedactEnv)
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// imageHistories fills in the history of each of images, fetching as many of
+// them at once as sem allows. An image whose history can't be fetched is
+// logged and left without it.
+func imageHistories(ctx context.Context, cli Docker, sem *semaphore.Weighted, images []*Image, opts Options) error {
+	g, gctx := errgroup.WithContext(ctx)
+	done := progress(opts, "fetching image histories", len(images))
+	for _, i := range images {
+		i := i
+		g.Go(func() error {
+			defer done()
+			if err := sem.Acquire(gctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+			callCtx := gctx
+			if opts.HistoryTimeout > 0 {
+				var cancel context.CancelFunc
+				callCtx, cancel = context.WithTimeout(gctx, opts.HistoryTimeout)
+				defer cancel()
+			}
+			var history []*HistoryItem
+			err := Retry(callCtx, "getting image history", opts.Retries, opts.RetryInterval, func() (err error) {
+				history, err = cli.ImageHistory(callCtx, i.ID)
+				return err
+			})
+			if err != nil && gctx.Err() != nil {
+				return fmt.Errorf("getting history of image %s: %s", i.ID, err)
+			}
+			if err != nil {
+				slog.Warn("getting image history failed, skipping it", "image", i.ID, "error", err)
+				return nil
+			}
+			i.History = history
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// containerStats fills in the stats of each of the running containers,
+// fetching as many of them at once as sem allows. A container whose stats
+// can't be fetched in time is logged and left without them.
+func containerStats(ctx context.Context, cli Docker, sem *semaphore.Weighted, containers []*Container, opts Options) error {
+	g, gctx := errgroup.WithContext(ctx)
+	var running []*Container
+	for _, c := range containers {
+		if c.State == "running" {
+			running = append(running, c)
+		}
+	}
+	done := progress(opts, "fetching container stats", len(running))
+	for _, c := range running {
+		c := c
+		g.Go(func() error {
+			defer done()
+			if err := sem.Acquire(gctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+			callCtx := gctx
+			if opts.StatsTimeout > 0 {
+				var cancel context.CancelFunc
+				callCtx, cancel = context.WithTimeout(gctx, opts.StatsTimeout)
+				defer cancel()
+			}
+			stats, err := cli.ContainerStats(callCtx, c.ID)
+			if err != nil && gctx.Err() != nil {
+				return fmt.Errorf("getting stats of container %s: %s", c.ID, err)
+			}
+			if err != nil {
+				slog.Warn("getting container stats failed, skipping it", "container", c.ID, "error", err)
+				return nil
+			}
+			c.Stats = stats
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// containerLogs fills in the last opts.ContainerLogs lines each of containers
+// logged, fetching as many of them at once as sem allows, and then limits
+// them to opts.LogsMaxBytes in total. A container whose logs can't be read is
+// logged and left with an empty log and the reason in LogsError.
+func containerLogs(ctx context.Context, cli Docker, sem *semaphore.Weighted, containers []*Container, opts Options) error {
+	g, gctx := errgroup.WithContext(ctx)
+	done := progress(opts, "fetching container logs", len(containers))
+	for _, c := range containers {
+		c := c
+		g.Go(func() error {
+			defer done()
+			if err := sem.Acquire(gctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+			logs, err := cli.ContainerLogs(gctx, c.ID, opts.ContainerLogs)
+			if err != nil && gctx.Err() != nil {
+				return fmt.Errorf("getting logs of container %s: %s", c.ID, err)
+			}
+			if err != nil {
+				slog.Warn("getting container logs failed, leaving them empty", "container", c.ID, "error", err)
+				c.LogsError = err.Error()
+				return nil
+			}
+			c.Logs = logs
+			return nil
+		})
+	}
+	// The logs that were read are limited even if the rest weren't, so that
+	// a section timeout doesn't leave them over the limit.
+	err := g.Wait()
+	limitLogs(containers, opts.LogsMaxBytes)
+	return err
+}
+
+// progress returns a function to call as each of the total objects what
+// describes is done, which reports how many are done to opts.Progress.
+func progress(opts Options, what string, total int) func() {
+	if opts.Progress == nil {
+		return func() {}
+	}
+	var done int64
+	return func() {
+		opts.Progress(what, int(atomic.AddInt64(&done, 1)), total)
+	}
+}
+
+// logListed logs how many objects a listing found and how long it took.
+func logListed(what string, count int, start time.Time) {
+	slog.Debug("listed "+what, "count", count, "duration", time.Since(start))
+}
@@ -0,0 +1,499 @@
+package fester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDocker is a Docker that answers from canned lists instead of a daemon.
+// errs makes the named method fail, and delay makes each of the per-object
+// calls take that long, so that they overlap. It counts the calls to each
+// method, and the most per-object calls that were ever in flight at once.
+type fakeDocker struct {
+	apiVersion string
+	images     []*Image
+	containers []*Container
+	volumes    []*Volume
+	networks   []*Network
+	system     *System
+	details    map[string]*ContainerDetails
+	histories  map[string][]*HistoryItem
+	logs       map[string]string
+	errs       map[string]error
+	delay      time.Duration
+
+	mu          sync.Mutex
+	calls       map[string]int
+	inFlight    int
+	maxInFlight int
+}
+
+// call records a call to the named method and returns the error it's
+// configured to fail with, if any.
+func (f *fakeDocker) call(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[method]++
+	return f.errs[method]
+}
+
+// perObject records a call to the named per-object method, keeping it in
+// flight for f.delay, and returns the error it's configured to fail with.
+func (f *fakeDocker) perObject(ctx context.Context, method string) error {
+	if err := f.call(method); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(f.delay):
+	}
+	return nil
+}
+
+// count returns how many times the named method was called.
+func (f *fakeDocker) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// copyImage returns a copy of i that Collect can change without changing i.
+func copyImage(i *Image) *Image {
+	c := *i
+	c.RepoTags = append([]string(nil), i.RepoTags...)
+	c.RepoDigests = append([]string(nil), i.RepoDigests...)
+	return &c
+}
+
+// copyContainer returns a copy of c that Collect can change without changing
+// c.
+func copyContainer(c *Container) *Container {
+	cc := *c
+	cc.Names = append([]string(nil), c.Names...)
+	return &cc
+}
+
+func (f *fakeDocker) APIVersion(ctx context.Context) (string, error) {
+	if f.apiVersion == "" {
+		return "1.47", f.call("APIVersion")
+	}
+	return f.apiVersion, f.call("APIVersion")
+}
+
+func (f *fakeDocker) Pull(ctx context.Context, image string) error {
+	return f.call("Pull")
+}
+
+func (f *fakeDocker) Version(ctx context.Context, image string) (*VersionInfo, error) {
+	return &VersionInfo{AppVersion: "1.0.0"}, f.call("Version")
+}
+
+func (f *fakeDocker) ListImages(ctx context.Context, filters Filters) ([]*Image, error) {
+	if err := f.call("ListImages"); err != nil {
+		return nil, err
+	}
+	images := []*Image{}
+	for _, i := range f.images {
+		images = append(images, copyImage(i))
+	}
+	return images, nil
+}
+
+func (f *fakeDocker) InspectImage(ctx context.Context, ref string) (*Image, error) {
+	if err := f.perObject(ctx, "InspectImage"); err != nil {
+		return nil, err
+	}
+	for _, i := range f.images {
+		if i.ID == ref {
+			return copyImage(i), nil
+		}
+		for _, t := range i.RepoTags {
+			if t == ref {
+				return copyImage(i), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no such image: %s", ref)
+}
+
+func (f *fakeDocker) ImageHistory(ctx context.Context, id string) ([]*HistoryItem, error) {
+	if err := f.perObject(ctx, "ImageHistory"); err != nil {
+		return nil, err
+	}
+	return f.histories[id], nil
+}
+
+func (f *fakeDocker) ImagePlatforms(ctx context.Context) (map[string][]*ImagePlatform, error) {
+	return nil, f.call("ImagePlatforms")
+}
+
+func (f *fakeDocker) ListContainers(ctx context.Context, filters Filters) ([]*Container, error) {
+	if err := f.call("ListContainers"); err != nil {
+		return nil, err
+	}
+	containers := []*Container{}
+	for _, c := range f.containers {
+		if status := filters["status"]; len(status) > 0 && status[0] != c.State {
+			continue
+		}
+		if id := filters["id"]; len(id) > 0 && id[0] != c.ID {
+			continue
+		}
+		containers = append(containers, copyContainer(c))
+	}
+	return containers, nil
+}
+
+func (f *fakeDocker) InspectContainer(ctx context.Context, id string) (*ContainerDetails, error) {
+	if err := f.perObject(ctx, "InspectContainer"); err != nil {
+		return nil, err
+	}
+	if d, ok := f.details[id]; ok {
+		return d, nil
+	}
+	return &ContainerDetails{}, nil
+}
+
+func (f *fakeDocker) ContainerStats(ctx context.Context, id string) (*ContainerStats, error) {
+	if err := f.perObject(ctx, "ContainerStats"); err != nil {
+		return nil, err
+	}
+	return &ContainerStats{}, nil
+}
+
+func (f *fakeDocker) ContainerLogs(ctx context.Context, id string, tail int) (string, error) {
+	if err := f.perObject(ctx, "ContainerLogs"); err != nil {
+		return "", err
+	}
+	return f.logs[id], nil
+}
+
+func (f *fakeDocker) ListVolumes(ctx context.Context) ([]*Volume, error) {
+	if err := f.call("ListVolumes"); err != nil {
+		return nil, err
+	}
+	return append([]*Volume{}, f.volumes...), nil
+}
+
+func (f *fakeDocker) ListNetworks(ctx context.Context) ([]*Network, error) {
+	if err := f.call("ListNetworks"); err != nil {
+		return nil, err
+	}
+	return append([]*Network{}, f.networks...), nil
+}
+
+func (f *fakeDocker) ListPlugins(ctx context.Context) ([]*Plugin, error) {
+	return []*Plugin{}, f.call("ListPlugins")
+}
+
+func (f *fakeDocker) SystemInfo(ctx context.Context) (*System, error) {
+	if err := f.call("SystemInfo"); err != nil {
+		return nil, err
+	}
+	if f.system == nil {
+		return &System{ServerVersion: "27.0.0"}, nil
+	}
+	return f.system, nil
+}
+
+func (f *fakeDocker) DiskUsage(ctx context.Context) ([]*DiskUsage, error) {
+	return []*DiskUsage{}, f.call("DiskUsage")
+}
+
+func (f *fakeDocker) Events(ctx context.Context, filters Filters, handle func(*Event)) error {
+	if err := f.call("Events"); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (f *fakeDocker) SwarmManager(ctx context.Context) (bool, error) {
+	return false, f.call("SwarmManager")
+}
+
+func (f *fakeDocker) ListServices(ctx context.Context) ([]*Service, error) {
+	return nil, f.call("ListServices")
+}
+
+func (f *fakeDocker) ListTasks(ctx context.Context, services []*Service) ([]*Task, error) {
+	return nil, f.call("ListTasks")
+}
+
+// testOptions returns Options for collecting from a fakeDocker: a fixed
+// hostname, so the host isn't looked up, and no retries, so failures are
+// returned at once.
+func testOptions() Options {
+	return Options{Hostname: "test-host", Sort: true, RetryInterval: time.Millisecond}
+}
+
+// testImages and testContainers are a small host: two tagged images, one of
+// which a container was started from, and a dangling one.
+func testImages() []*Image {
+	return []*Image{
+		{ID: "sha256:aaa", RepoTags: []string{"example.com/app:1.0"}, Size: 100},
+		{ID: "sha256:bbb", RepoTags: []string{"redis:7"}, Size: 50},
+		{ID: "sha256:ccc", RepoTags: []string{"<none>:<none>"}, Size: 10, Dangling: true},
+	}
+}
+
+func testContainers() []*Container {
+	return []*Container{
+		{ID: "c1", Names: []string{"/app"}, Image: "example.com/app:1.0", ImageID: "sha256:aaa", State: "running"},
+		{ID: "c2", Names: []string{"/job"}, Image: "example.com/app:1.0", ImageID: "sha256:aaa", State: "exited"},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	failure := errors.New("Error response from daemon: boom")
+	tests := []struct {
+		name    string
+		docker  *fakeDocker
+		opts    func(*Options)
+		wantErr bool
+		check   func(t *testing.T, o *OutputMap)
+	}{
+		{
+			name:   "empty host",
+			docker: &fakeDocker{},
+			check: func(t *testing.T, o *OutputMap) {
+				if o.Images == nil || len(o.Images) != 0 {
+					t.Errorf("Images = %#v, want an empty list", o.Images)
+				}
+				if o.Containers == nil || len(o.Containers) != 0 {
+					t.Errorf("Containers = %#v, want an empty list", o.Containers)
+				}
+				if o.Volumes == nil || o.Networks == nil {
+					t.Errorf("Volumes = %#v, Networks = %#v, want empty lists", o.Volumes, o.Networks)
+				}
+				s := o.Summary
+				if s.ImageCount != 0 || s.ContainerCount != 0 || s.RunningContainerCount != 0 || s.TotalImageSizeBytes != 0 {
+					t.Errorf("Summary = %+v, want zero counts", s)
+				}
+				if len(o.Errors) != 0 {
+					t.Errorf("Errors = %v, want none", o.Errors)
+				}
+			},
+		},
+		{
+			name:   "images and containers",
+			docker: &fakeDocker{images: testImages(), containers: testContainers(), volumes: []*Volume{{Name: "data"}}},
+			check: func(t *testing.T, o *OutputMap) {
+				if o.Hostname != "test-host" || o.DockerAPIVersion != "1.47" || o.SchemaVersion != SchemaVersion {
+					t.Errorf("Hostname, DockerAPIVersion, SchemaVersion = %q, %q, %q", o.Hostname, o.DockerAPIVersion, o.SchemaVersion)
+				}
+				if len(o.Images) != 3 || len(o.Containers) != 2 || len(o.Volumes) != 1 {
+					t.Fatalf("got %d images, %d containers, %d volumes, want 3, 2, 1", len(o.Images), len(o.Containers), len(o.Volumes))
+				}
+				s := o.Summary
+				if s.ImageCount != 3 || s.ContainerCount != 2 || s.RunningContainerCount != 1 || s.TotalImageSizeBytes != 160 {
+					t.Errorf("Summary = %+v", s)
+				}
+				if s.UnusedImageCount != 2 || s.UnusedImageSizeBytes != 60 {
+					t.Errorf("unused = %d, %d bytes, want 2, 60", s.UnusedImageCount, s.UnusedImageSizeBytes)
+				}
+				for _, i := range o.Images {
+					if want := i.ID == "sha256:aaa"; i.InUse != want {
+						t.Errorf("image %s InUse = %t, want %t", i.ID, i.InUse, want)
+					}
+					if want := i.ID == "sha256:ccc"; i.Dangling != want {
+						t.Errorf("image %s Dangling = %t, want %t", i.ID, i.Dangling, want)
+					}
+				}
+				if o.System == nil || o.System.ServerVersion != "27.0.0" {
+					t.Errorf("System = %+v", o.System)
+				}
+			},
+		},
+		{
+			name:   "running only",
+			docker: &fakeDocker{images: testImages(), containers: testContainers()},
+			opts:   func(o *Options) { o.RunningOnly = true },
+			check: func(t *testing.T, o *OutputMap) {
+				if len(o.Containers) != 1 || o.Containers[0].ID != "c1" {
+					t.Errorf("Containers = %v, want only c1", o.Containers)
+				}
+				if len(o.Images) != 1 || o.Images[0].ID != "sha256:aaa" {
+					t.Errorf("Images = %v, want only sha256:aaa", o.Images)
+				}
+			},
+		},
+		{
+			name:   "images skipped",
+			docker: &fakeDocker{images: testImages(), containers: testContainers()},
+			opts:   func(o *Options) { o.SkipImages = true },
+			check: func(t *testing.T, o *OutputMap) {
+				if o.Images != nil {
+					t.Errorf("Images = %v, want nil", o.Images)
+				}
+				if len(o.Containers) != 2 {
+					t.Errorf("got %d containers, want 2", len(o.Containers))
+				}
+			},
+		},
+		{
+			name:   "containers skipped",
+			docker: &fakeDocker{images: testImages(), containers: testContainers()},
+			opts:   func(o *Options) { o.SkipContainers = true },
+			check: func(t *testing.T, o *OutputMap) {
+				if o.Containers != nil {
+					t.Errorf("Containers = %v, want nil", o.Containers)
+				}
+				if o.Summary.UnusedImageCount != 0 {
+					t.Errorf("UnusedImageCount = %d, want 0 when containers aren't listed", o.Summary.UnusedImageCount)
+				}
+			},
+		},
+		{
+			name:   "a failed listing is recorded",
+			docker: &fakeDocker{images: testImages(), containers: testContainers(), errs: map[string]error{"ListContainers": failure}},
+			check: func(t *testing.T, o *OutputMap) {
+				if len(o.Images) != 3 {
+					t.Errorf("got %d images, want 3", len(o.Images))
+				}
+				if o.Containers != nil {
+					t.Errorf("Containers = %v, want nil", o.Containers)
+				}
+				if len(o.Errors) != 1 || o.Errors[0] != "listing containers: "+failure.Error() {
+					t.Errorf("Errors = %q", o.Errors)
+				}
+			},
+		},
+		{
+			name:    "a failed listing fails with Strict",
+			docker:  &fakeDocker{images: testImages(), errs: map[string]error{"ListContainers": failure}},
+			opts:    func(o *Options) { o.Strict = true },
+			wantErr: true,
+		},
+		{
+			name:    "no API version",
+			docker:  &fakeDocker{errs: map[string]error{"APIVersion": failure}},
+			wantErr: true,
+		},
+		{
+			name:   "inspected containers",
+			docker: &fakeDocker{containers: testContainers(), details: map[string]*ContainerDetails{"c1": {Env: []string{"DB_PASSWORD=x", "MODE=prod"}, RestartCount: 4}}},
+			opts: func(o *Options) {
+				o.InspectContainers = true
+				o.RedactEnv = []string{"password"}
+				o.RestartThreshold = 3
+			},
+			check: func(t *testing.T, o *OutputMap) {
+				c := o.Containers[0]
+				if c.ID != "c1" || len(c.Env) != 2 || c.Env[0] != "DB_PASSWORD=***" || c.Env[1] != "MODE=prod" {
+					t.Errorf("container %s Env = %v", c.ID, c.Env)
+				}
+				if o.Summary.MaxRestartCount != 4 || len(o.Summary.RestartedContainers) != 1 || o.Summary.RestartedContainers[0] != "app" {
+					t.Errorf("restarts = %d, %v", o.Summary.MaxRestartCount, o.Summary.RestartedContainers)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions()
+			if tt.opts != nil {
+				tt.opts(&opts)
+			}
+			o, err := Collect(context.Background(), tt.docker, opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Collect succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Collect: %s", err)
+			}
+			tt.check(t, o)
+		})
+	}
+}
+
+func TestCollectMaxConcurrency(t *testing.T) {
+	var images []*Image
+	var containers []*Container
+	for i := 0; i < 30; i++ {
+		id := fmt.Sprintf("sha256:%03d", i)
+		images = append(images, &Image{ID: id, RepoTags: []string{fmt.Sprintf("app:%d", i)}})
+		containers = append(containers, &Container{ID: fmt.Sprintf("c%d", i), Names: []string{fmt.Sprintf("/app-%d", i)}, ImageID: id, State: "running"})
+	}
+	for _, limit := range []int{1, 3, 0} {
+		want := limit
+		if want == 0 {
+			want = DefaultMaxConcurrency
+		}
+		t.Run(fmt.Sprintf("MaxConcurrency %d", limit), func(t *testing.T) {
+			fake := &fakeDocker{images: images, containers: containers, delay: 5 * time.Millisecond}
+			opts := testOptions()
+			opts.MaxConcurrency = limit
+			opts.InspectContainers = true
+			opts.Stats = true
+			opts.ContainerLogs = 10
+			opts.ImageHistory = true
+			if _, err := Collect(context.Background(), fake, opts); err != nil {
+				t.Fatalf("Collect: %s", err)
+			}
+			for _, method := range []string{"InspectContainer", "ContainerStats", "ContainerLogs", "ImageHistory"} {
+				if n := fake.count(method); n != 30 {
+					t.Errorf("%s was called %d times, want 30", method, n)
+				}
+			}
+			if fake.maxInFlight != want {
+				t.Errorf("%d calls were in flight at once, want %d", fake.maxInFlight, want)
+			}
+		})
+	}
+}
+
+func TestCollectFiltersBeforeHistories(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func(*Options)
+		want int
+	}{
+		{"no filters", func(*Options) {}, 3},
+		{"registries", func(o *Options) { o.ImageRegistries = []string{"example.com"} }, 1},
+		{"exclusions", func(o *Options) { o.ExcludeImages = []string{"redis:*"} }, 2},
+		{"created", func(o *Options) { o.ImageCreatedAfter = time.Unix(1000, 0) }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := testImages()
+			images[0].Created = 2000
+			fake := &fakeDocker{images: images, containers: testContainers()}
+			opts := testOptions()
+			opts.ImageHistory = true
+			tt.opts(&opts)
+			o, err := Collect(context.Background(), fake, opts)
+			if err != nil {
+				t.Fatalf("Collect: %s", err)
+			}
+			if len(o.Images) != tt.want {
+				t.Errorf("listed %d images, want %d", len(o.Images), tt.want)
+			}
+			if n := fake.count("ImageHistory"); n != tt.want {
+				t.Errorf("fetched %d histories for %d images", n, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package fester
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileEntry describes a file included in the manifest.
+type FileEntry struct {
+	Path    string    `json:"path" yaml:"path"`
+	Size    int64     `json:"size" yaml:"size"`
+	ModTime time.Time `json:"mod_time" yaml:"mod_time"`
+	SHA256  string    `json:"sha256" yaml:"sha256"`
+	// Content is the base64-encoded contents of the file, when they are
+	// embedded. Truncated is set instead if the file was too big to embed.
+	Content   string `json:"content,omitempty" yaml:"content,omitempty"`
+	Truncated bool   `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+}
+
+// NewFileEntry returns a *FileEntry describing the file at path. If embedMax
+// is more than zero, the contents of the file are embedded if it is no bigger
+// than embedMax bytes. Only that much of the file is ever held in memory.
+func NewFileEntry(path string, embedMax int64) (*FileEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	var content []byte
+	if embedMax > 0 && info.Size() <= embedMax {
+		// Read one byte more than allowed to catch files that grew since
+		// they were statted.
+		content, err = ioutil.ReadAll(io.LimitReader(file, embedMax+1))
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(content)
+	}
+	n, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, err
+	}
+	f := &FileEntry{
+		Path:    path,
+		Size:    int64(len(content)) + n,
+		ModTime: info.ModTime(),
+		SHA256:  hex.EncodeToString(hash.Sum(nil)),
+	}
+	if embedMax > 0 {
+		if f.Size <= embedMax {
+			f.Content = base64.StdEncoding.EncodeToString(content)
+		} else {
+			f.Truncated = true
+		}
+	}
+	return f, nil
+}
+
+// ExpandFiles expands each of patterns with filepath.Glob and returns the
+// matching paths, sorted and without duplicates. A "**" in a pattern matches
+// any number of directories, so /etc/**/*.conf matches every .conf file under
+// /etc. Patterns that match nothing, and directories matched by a pattern
+// without "**", are skipped with a warning.
+func ExpandFiles(patterns []string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := expandPattern(pattern)
+		if err != nil {
+			slog.Warn("skipping file pattern", "pattern", pattern, "error", err)
+			continue
+		}
+		if len(matches) == 0 {
+			slog.Warn("file pattern matched nothing", "pattern", pattern)
+		}
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				slog.Warn("skipping directory", "path", path, "pattern", pattern)
+				continue
+			}
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// expandPattern returns the paths matching pattern. See ExpandFiles. The part
+// of a pattern before "**" is globbed too, so /opt/*/logs/**/*.log walks the
+// logs directory of every directory in /opt.
+func expandPattern(pattern string) ([]string, error) {
+	n := strings.Index(pattern, "**")
+	if n < 0 {
+		return filepath.Glob(pattern)
+	}
+	roots, err := filepath.Glob(filepath.Clean(pattern[:n]))
+	if err != nil {
+		return nil, err
+	}
+	rest := strings.TrimLeft(pattern[n+2:], string(filepath.Separator))
+	if _, err := filepath.Match(rest, rest); err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, root := range roots {
+		found, err := walkPattern(root, rest)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, found...)
+	}
+	return matches, nil
+}
+
+// walkPattern returns the files under root whose path ends with a match for
+// rest, or every file under root if rest is empty.
+func walkPattern(root, rest string) ([]string, error) {
+	depth := len(strings.Split(rest, string(filepath.Separator)))
+	var matches []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			slog.Warn("skipping path", "path", path, "error", err)
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if rest == "" {
+			matches = append(matches, path)
+			return nil
+		}
+		parts := strings.Split(path, string(filepath.Separator))
+		if len(parts) < depth {
+			return nil
+		}
+		if ok, _ := filepath.Match(rest, filepath.Join(parts[len(parts)-depth:]...)); ok {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return matches, err
+}
+
+// ReadFiles returns a *FileEntry for each of the files matching patterns, as
+// expanded by ExpandFiles, sorted by path. embedMax is passed on to
+// NewFileEntry. Files that can't be read are skipped with a warning, and so
+// are globs that match nothing. An error is only returned if there were files
+// to read but none of them could be: files were matched, or paths without
+// glob characters were given, since a missing path is a file that couldn't be
+// read rather than a pattern that matched nothing.
+func ReadFiles(patterns []string, embedMax int64) ([]*FileEntry, error) {
+	var entries []*FileEntry
+	listed := false
+	for _, pattern := range patterns {
+		listed = listed || !strings.ContainsAny(pattern, "*?[")
+	}
+	paths := ExpandFiles(patterns)
+	if len(paths) == 0 && !listed {
+		return entries, nil
+	}
+	for _, path := range paths {
+		f, err := NewFileEntry(path, embedMax)
+		if err != nil {
+			slog.Warn("skipping file", "path", path, "error", err)
+			continue
+		}
+		entries = append(entries, f)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("none of the listed files could be read")
+	}
+	return entries, nil
+}
@@ -0,0 +1,113 @@
+package fester
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree creates each of files under dir, with its name as its contents,
+// and returns dir.
+func writeTree(t *testing.T, files ...string) string {
+	dir := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestExpandFiles(t *testing.T) {
+	dir := writeTree(t,
+		"etc/app.conf",
+		"etc/conf.d/a.conf",
+		"etc/conf.d/deep/b.conf",
+		"etc/conf.d/notes.txt",
+		"opt/one/logs/x.log",
+		"opt/one/logs/old/y.log",
+		"opt/two/logs/z.log",
+		"opt/two/VERSION",
+	)
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{"a literal path", []string{"etc/app.conf"}, []string{"etc/app.conf"}},
+		{"a missing path", []string{"etc/missing.conf"}, nil},
+		{"a glob", []string{"opt/*/VERSION"}, []string{"opt/two/VERSION"}},
+		{"a directory is skipped", []string{"etc/*"}, []string{"etc/app.conf"}},
+		{
+			"** matches any depth",
+			[]string{"etc/**/*.conf"},
+			[]string{"etc/app.conf", "etc/conf.d/a.conf", "etc/conf.d/deep/b.conf"},
+		},
+		{
+			"** on its own matches every file",
+			[]string{"etc/conf.d/**"},
+			[]string{"etc/conf.d/a.conf", "etc/conf.d/deep/b.conf", "etc/conf.d/notes.txt"},
+		},
+		{
+			"a glob before **",
+			[]string{"opt/*/logs/**/*.log"},
+			[]string{"opt/one/logs/old/y.log", "opt/one/logs/x.log", "opt/two/logs/z.log"},
+		},
+		{
+			"duplicates are dropped and paths sorted",
+			[]string{"opt/two/VERSION", "etc/app.conf", "etc/*.conf"},
+			[]string{"etc/app.conf", "opt/two/VERSION"},
+		},
+		{"a bad pattern is skipped", []string{"etc/[", "etc/app.conf"}, []string{"etc/app.conf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patterns, want []string
+			for _, p := range tt.patterns {
+				patterns = append(patterns, filepath.Join(dir, filepath.FromSlash(p)))
+			}
+			for _, p := range tt.want {
+				want = append(want, filepath.Join(dir, filepath.FromSlash(p)))
+			}
+			if got := ExpandFiles(patterns); !reflect.DeepEqual(got, want) {
+				t.Errorf("ExpandFiles(%q) = %q, want %q", tt.patterns, got, want)
+			}
+		})
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := writeTree(t, "a.conf", "b.conf")
+	path := func(name string) string { return filepath.Join(dir, name) }
+	tests := []struct {
+		name     string
+		patterns []string
+		want     int
+		wantErr  bool
+	}{
+		{"no patterns", nil, 0, false},
+		{"every file is read", []string{path("*.conf")}, 2, false},
+		{"a missing file is skipped", []string{path("a.conf"), path("missing")}, 1, false},
+		{"no listed file exists", []string{path("missing1"), path("missing2")}, 0, true},
+		{"a glob that matches nothing", []string{path("*.log")}, 0, false},
+		{"globs that match nothing", []string{path("*.log"), path("logs/**/*.log")}, 0, false},
+		{"a glob that matches nothing and a missing file", []string{path("*.log"), path("missing")}, 0, true},
+		{"a glob that matches nothing and a file", []string{path("*.log"), path("a.conf")}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ReadFiles(tt.patterns, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFiles(%q) error = %v, want an error: %t", tt.patterns, err, tt.wantErr)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("ReadFiles(%q) read %d files, want %d", tt.patterns, len(entries), tt.want)
+			}
+		})
+	}
+}
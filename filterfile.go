@@ -0,0 +1,135 @@
+package fester
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImageFilterKeys lists the docker filters that can narrow down the images
+// listed, and ContainerFilterKeys those for containers.
+var (
+	ImageFilterKeys     = []string{"before", "dangling", "label", "reference", "since", "until"}
+	ContainerFilterKeys = []string{"ancestor", "before", "expose", "exited", "health", "id", "isolation", "is-task", "label", "name", "network", "publish", "since", "status", "volume"}
+)
+
+// FilterFile holds the filter, exclusion, and label promotion rules read from
+// a -filter-file, each of which works like the command line flag of the same
+// name.
+type FilterFile struct {
+	ImageFilters      Filters  `json:"image_filters" yaml:"image_filters"`
+	ContainerFilters  Filters  `json:"container_filters" yaml:"container_filters"`
+	ExcludeImages     []string `json:"exclude_images" yaml:"exclude_images"`
+	ExcludeContainers []string `json:"exclude_containers" yaml:"exclude_containers"`
+	PromoteLabels     []string `json:"promote_labels" yaml:"promote_labels"`
+}
+
+// ReadFilterFile reads and validates the filter file at filename, which may be
+// JSON or YAML. Fields it doesn't know about are an error, so that a
+// misspelled rule isn't quietly ignored.
+func ReadFilterFile(filename string) (*FilterFile, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	f := &FilterFile{}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(f)
+	} else if len(bytes.TrimSpace(content)) > 0 {
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		err = dec.Decode(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", filename, err)
+	}
+	if err = f.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return f, nil
+}
+
+// Validate returns an error if any of the rules can't be used: a filter docker
+// doesn't have, a container status or dangling value docker wouldn't accept,
+// a malformed glob pattern, or an empty label key.
+func (f *FilterFile) Validate() error {
+	if err := validateFilters("image_filters", f.ImageFilters, ImageFilterKeys); err != nil {
+		return err
+	}
+	if err := validateFilters("container_filters", f.ContainerFilters, ContainerFilterKeys); err != nil {
+		return err
+	}
+	for _, v := range f.ImageFilters["dangling"] {
+		if v != "true" && v != "false" {
+			return fmt.Errorf("image_filters: dangling must be true or false, not %q", v)
+		}
+	}
+	if len(f.ImageFilters["dangling"]) > 1 {
+		return errors.New("image_filters: dangling may only be given once")
+	}
+	for _, v := range f.ContainerFilters["status"] {
+		if !ValidContainerState(v) {
+			return fmt.Errorf("container_filters: status must be one of: %s", strings.Join(ContainerStates, ", "))
+		}
+	}
+	for _, p := range append(append([]string{}, f.ExcludeImages...), f.ExcludeContainers...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("bad exclusion pattern %q: %s", p, err)
+		}
+	}
+	for _, key := range f.PromoteLabels {
+		if key == "" {
+			return errors.New("promote_labels: label keys can't be empty")
+		}
+	}
+	return nil
+}
+
+// validateFilters returns an error if filters uses a key that isn't one of
+// keys, or gives one an empty value.
+func validateFilters(field string, filters Filters, keys []string) error {
+	var names []string
+	for k := range filters {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if !slices.Contains(keys, k) {
+			return fmt.Errorf("%s: unknown filter %q, must be one of: %s", field, k, strings.Join(keys, ", "))
+		}
+		for _, v := range filters[k] {
+			if v == "" {
+				return fmt.Errorf("%s: %s has an empty value", field, k)
+			}
+		}
+	}
+	return nil
+}
+
+// MergeFilters returns the filters in base with those in extra added. The
+// values for a key present in both are appended, except for the keys in
+// replace, for which extra's values win.
+func MergeFilters(base, extra Filters, replace ...string) Filters {
+	merged := Filters{}
+	for k, v := range base {
+		merged[k] = append([]string{}, v...)
+	}
+	for k, v := range extra {
+		if slices.Contains(replace, k) {
+			merged[k] = append([]string{}, v...)
+		} else {
+			merged[k] = append(merged[k], v...)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,128 @@
+package fester
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFilterFile(t *testing.T) {
+	want := &FilterFile{
+		ImageFilters:      Filters{"reference": {"registry.example.com/*"}, "dangling": {"false"}},
+		ContainerFilters:  Filters{"label": {"com.example.managed"}, "status": {"running", "restarting"}},
+		ExcludeImages:     []string{"monitoring/*"},
+		ExcludeContainers: []string{"*-sidecar"},
+		PromoteLabels:     []string{"com.example.role"},
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    *FilterFile
+		wantErr string
+	}{
+		{
+			name: "YAML",
+			content: `image_filters:
+  reference: ["registry.example.com/*"]
+  dangling: ["false"]
+container_filters:
+  label: ["com.example.managed"]
+  status: ["running", "restarting"]
+exclude_images: ["monitoring/*"]
+exclude_containers: ["*-sidecar"]
+promote_labels: ["com.example.role"]
+`,
+			want: want,
+		},
+		{
+			name: "JSON",
+			content: `{
+  "image_filters": {"reference": ["registry.example.com/*"], "dangling": ["false"]},
+  "container_filters": {"label": ["com.example.managed"], "status": ["running", "restarting"]},
+  "exclude_images": ["monitoring/*"],
+  "exclude_containers": ["*-sidecar"],
+  "promote_labels": ["com.example.role"]
+}`,
+			want: want,
+		},
+		{name: "empty", content: "", want: &FilterFile{}},
+		{name: "some fields", content: "exclude_images: [\"test/*\"]\n", want: &FilterFile{ExcludeImages: []string{"test/*"}}},
+		{name: "an unknown YAML field", content: "exclude_image: [\"test/*\"]\n", wantErr: "exclude_image"},
+		{name: "an unknown JSON field", content: `{"exclude_image": ["test/*"]}`, wantErr: "exclude_image"},
+		{name: "an unknown image filter", content: "image_filters:\n  tag: [\"1.0\"]\n", wantErr: `unknown filter "tag"`},
+		{name: "an unknown container filter", content: "container_filters:\n  state: [\"running\"]\n", wantErr: `unknown filter "state"`},
+		{name: "an empty filter value", content: "container_filters:\n  label: [\"\"]\n", wantErr: "empty value"},
+		{name: "a bad status", content: "container_filters:\n  status: [\"stopped\"]\n", wantErr: "status must be one of"},
+		{name: "a bad dangling value", content: "image_filters:\n  dangling: [\"yes\"]\n", wantErr: "dangling must be true or false"},
+		{name: "dangling twice", content: "image_filters:\n  dangling: [\"true\", \"false\"]\n", wantErr: "dangling may only be given once"},
+		{name: "a bad image glob", content: "exclude_images: [\"app[\"]\n", wantErr: "bad exclusion pattern"},
+		{name: "a bad container glob", content: "exclude_containers: [\"[a-\"]\n", wantErr: "bad exclusion pattern"},
+		{name: "an empty label key", content: "promote_labels: [\"\"]\n", wantErr: "can't be empty"},
+		{name: "malformed YAML", content: "exclude_images: [\n", wantErr: "parsing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "filters")
+			if err := os.WriteFile(filename, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadFilterFile(filename)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadFilterFile error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadFilterFile: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadFilterFile = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if _, err := ReadFilterFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("ReadFilterFile of a missing file succeeded")
+	}
+}
+
+func TestMergeFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		base, extra Filters
+		replace     []string
+		want        Filters
+	}{
+		{"nothing", nil, nil, nil, Filters{}},
+		{"only the file", Filters{"label": {"a"}}, nil, nil, Filters{"label": {"a"}}},
+		{"only the flags", nil, Filters{"label": {"b"}}, nil, Filters{"label": {"b"}}},
+		{"labels append", Filters{"label": {"a"}}, Filters{"label": {"b"}}, nil, Filters{"label": {"a", "b"}}},
+		{"statuses append", Filters{"status": {"running"}}, Filters{"status": {"exited"}}, nil, Filters{"status": {"running", "exited"}}},
+		{"different keys", Filters{"reference": {"app:*"}}, Filters{"since": {"id"}}, nil, Filters{"reference": {"app:*"}, "since": {"id"}}},
+		{
+			"a flag's dangling replaces the file's",
+			Filters{"dangling": {"false"}, "reference": {"app:*"}},
+			Filters{"dangling": {"true"}, "reference": {"db:*"}},
+			[]string{"dangling"},
+			Filters{"dangling": {"true"}, "reference": {"app:*", "db:*"}},
+		},
+		{"the file's dangling is kept without the flag", Filters{"dangling": {"false"}}, Filters{}, []string{"dangling"}, Filters{"dangling": {"false"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before Filters
+			if tt.base != nil {
+				before = MergeFilters(nil, tt.base)
+			}
+			got := MergeFilters(tt.base, tt.extra, tt.replace...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeFilters = %v, want %v", got, tt.want)
+			}
+			if tt.base != nil && !reflect.DeepEqual(tt.base, before) {
+				t.Errorf("MergeFilters changed the base filters to %v", tt.base)
+			}
+		})
+	}
+}
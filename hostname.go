@@ -0,0 +1,59 @@
+package fester
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// hostnameTimeout is how long Hostname waits for DNS.
+const hostnameTimeout = 2 * time.Second
+
+// Hostname returns the fully qualified domain name of the host fester runs
+// on, found by a reverse lookup of the addresses its hostname resolves to. If
+// that fails it returns the hostname as os.Hostname reports it, or an empty
+// string if even that fails.
+func Hostname(ctx context.Context) string {
+	short, err := os.Hostname()
+	if err != nil {
+		slog.Warn("getting hostname failed, leaving it out", "error", err)
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, hostnameTimeout)
+	defer cancel()
+	fqdn, err := lookupFQDN(ctx, short)
+	if err != nil {
+		slog.Debug("resolving the FQDN failed, using the hostname", "hostname", short, "error", err)
+		return short
+	}
+	return fqdn
+}
+
+// lookupFQDN returns the first name with a domain, other than localhost's, that
+// the addresses of host reverse resolve to.
+func lookupFQDN(ctx context.Context, host string) (string, error) {
+	if strings.Contains(host, ".") {
+		return host, nil
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			name = strings.TrimSuffix(name, ".")
+			if strings.Contains(name, ".") && !strings.HasPrefix(name, "localhost") {
+				return name, nil
+			}
+		}
+	}
+	return "", errors.New("none of the host's addresses resolve to a fully qualified name")
+}
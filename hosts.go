@@ -0,0 +1,204 @@
+package fester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Host is a Docker daemon to collect from, along with the URI it is known by.
+type Host struct {
+	URI    string
+	Client Docker
+}
+
+// Source describes one of the daemons an aggregated manifest was collected
+// from. Error is set if nothing could be collected from it, TimedOut names
+// the sections that ran out of time on it, and Timings gives how long each
+// section took on it.
+type Source struct {
+	URI              string             `json:"uri" yaml:"uri"`
+	DockerAPIVersion string             `json:"docker_api_version,omitempty" yaml:"docker_api_version,omitempty"`
+	System           *System            `json:"system,omitempty" yaml:"system,omitempty"`
+	TimedOut         []string           `json:"timed_out,omitempty" yaml:"timed_out,omitempty"`
+	Errors           []string           `json:"errors,omitempty" yaml:"errors,omitempty"`
+	Timings          map[string]float64 `json:"timings,omitempty" yaml:"timings,omitempty"`
+	Error            string             `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// CollectAll collects from each of the hosts concurrently and merges the
+// results into a single manifest. With a single host it is the same as
+// Collect. Otherwise every image, container, volume, network, and disk usage
+// entry is annotated with the URI of the host it came from, and the manifest
+// lists each host under Sources.
+//
+// A host that can't be collected from is logged and recorded in Sources and
+// Errors, so the manifest is known to be incomplete, unless failFast is set, in which case the first failure cancels the rest and
+// is returned. An error is also returned if none of the hosts succeed.
+func CollectAll(ctx context.Context, hosts []Host, opts Options, failFast bool) (*OutputMap, error) {
+	if len(hosts) == 1 {
+		return Collect(ctx, hosts[0].Client, opts)
+	}
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	outputs := make([]*OutputMap, len(hosts))
+	errs := make([]error, len(hosts))
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for n, h := range hosts {
+		wg.Add(1)
+		go func(n int, h Host) {
+			defer wg.Done()
+			outputs[n], errs[n] = Collect(ctx, h.Client, opts)
+			if errs[n] != nil && failFast {
+				once.Do(func() {
+					firstErr = fmt.Errorf("collecting from %s: %s", h.URI, errs[n])
+					cancel()
+				})
+			}
+		}(n, h)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	var merged *OutputMap
+	for _, o := range outputs {
+		if o != nil {
+			merged = newMerged(o)
+			break
+		}
+	}
+	if merged == nil {
+		return nil, errors.New("couldn't collect from any of the Docker hosts")
+	}
+	for n, h := range hosts {
+		if err := errs[n]; err != nil {
+			slog.Error("collecting from host failed", "uri", h.URI, "error", err)
+			merged.Sources = append(merged.Sources, &Source{URI: h.URI, Error: err.Error()})
+			merged.Errors = append(merged.Errors, h.URI+": "+err.Error())
+			continue
+		}
+		merged.merge(h.URI, outputs[n])
+	}
+	sort.Strings(merged.TimedOut)
+	sort.Strings(merged.Errors)
+	// The sections are timed per host, under Sources, so the merged manifest
+	// only records how long collecting from all of them took.
+	merged.Timings = map[string]float64{"total": seconds(time.Since(start))}
+	if opts.Sort {
+		SortImages(merged.Images)
+		SortContainers(merged.Containers)
+	}
+	merged.Labels = PromoteLabels(opts.Labels, merged.Containers, opts.PromoteLabels)
+	merged.Summary = NewSummary(merged.Images, merged.Containers)
+	for _, u := range merged.DiskUsage {
+		merged.Summary.TotalReclaimableBytes += u.ReclaimableBytes
+	}
+	merged.Summary.AddServices(merged.Services, merged.Tasks)
+	if opts.InspectContainers {
+		merged.Summary.AddRestarts(merged.Containers, opts.RestartThreshold)
+	}
+	for _, o := range outputs {
+		if o != nil {
+			merged.Summary.ExcludedImageCount += o.Summary.ExcludedImageCount
+			merged.Summary.ExcludedContainerCount += o.Summary.ExcludedContainerCount
+		}
+	}
+	return merged, nil
+}
+
+// newMerged returns an empty OutputMap to merge other manifests into, using
+// the fields of first that don't depend on the host.
+func newMerged(first *OutputMap) *OutputMap {
+	m := &OutputMap{
+		SchemaVersion: first.SchemaVersion,
+		FesterVersion: first.FesterVersion,
+		Hostname:      first.Hostname,
+		Date:          first.Date,
+		Labels:        first.Labels,
+		Files:         first.Files,
+		DockerImages:  make(map[string][]*VersionInfo),
+		Images:        []*Image{},
+		Containers:    []*Container{},
+		Volumes:       []*Volume{},
+		Networks:      []*Network{},
+	}
+	if first.Images == nil {
+		m.Images = nil
+	}
+	if first.Containers == nil {
+		m.Containers = nil
+	}
+	if first.Volumes == nil {
+		m.Volumes = nil
+	}
+	return m
+}
+
+// merge adds the host-specific contents of o, collected from uri.
+func (m *OutputMap) merge(uri string, o *OutputMap) {
+	m.Sources = append(m.Sources, &Source{
+		URI:              uri,
+		DockerAPIVersion: o.DockerAPIVersion,
+		System:           o.System,
+		TimedOut:         o.TimedOut,
+		Errors:           o.Errors,
+		Timings:          o.Timings,
+	})
+	for _, e := range o.Errors {
+		m.Errors = append(m.Errors, uri+": "+e)
+	}
+	for _, name := range o.TimedOut {
+		if !slices.Contains(m.TimedOut, name) {
+			m.TimedOut = append(m.TimedOut, name)
+		}
+	}
+	for name, versions := range o.DockerImages {
+		for _, v := range versions {
+			v.SourceURI = uri
+			m.DockerImages[name] = append(m.DockerImages[name], v)
+		}
+	}
+	for _, i := range o.Images {
+		i.SourceURI = uri
+		m.Images = append(m.Images, i)
+	}
+	for _, c := range o.Containers {
+		c.SourceURI = uri
+		m.Containers = append(m.Containers, c)
+	}
+	for _, v := range o.Volumes {
+		v.SourceURI = uri
+		m.Volumes = append(m.Volumes, v)
+	}
+	for _, n := range o.Networks {
+		n.SourceURI = uri
+		m.Networks = append(m.Networks, n)
+	}
+	for _, u := range o.DiskUsage {
+		u.SourceURI = uri
+		m.DiskUsage = append(m.DiskUsage, u)
+	}
+	for _, p := range o.Plugins {
+		p.SourceURI = uri
+		m.Plugins = append(m.Plugins, p)
+	}
+	for _, s := range o.Services {
+		s.SourceURI = uri
+		m.Services = append(m.Services, s)
+	}
+	for _, t := range o.Tasks {
+		t.SourceURI = uri
+		m.Tasks = append(m.Tasks, t)
+	}
+}
@@ -0,0 +1,39 @@
+package fester
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCollectAll(t *testing.T) {
+	unreachable := errors.New("Cannot connect to the Docker daemon")
+	hosts := []Host{
+		{URI: "tcp://a:2376", Client: &fakeDocker{images: testImages(), containers: testContainers()}},
+		{URI: "tcp://b:2376", Client: &fakeDocker{errs: map[string]error{"APIVersion": unreachable}}},
+		{URI: "tcp://c:2376", Client: &fakeDocker{images: testImages()[:1], errs: map[string]error{"ListVolumes": errors.New("boom")}}},
+	}
+	o, err := CollectAll(context.Background(), hosts, testOptions(), false)
+	if err != nil {
+		t.Fatalf("CollectAll: %s", err)
+	}
+	if len(o.Images) != 4 || len(o.Containers) != 2 {
+		t.Errorf("got %d images and %d containers, want 4 and 2", len(o.Images), len(o.Containers))
+	}
+	if len(o.Sources) != 3 || o.Sources[1].Error == "" {
+		t.Errorf("Sources = %+v, want the failed host's error recorded", o.Sources)
+	}
+	want := []string{"tcp://b:2376: getting Docker API version: ", "tcp://c:2376: listing volumes: boom"}
+	if len(o.Errors) != len(want) {
+		t.Fatalf("Errors = %q, want %d of them", o.Errors, len(want))
+	}
+	for n, prefix := range want {
+		if !strings.HasPrefix(o.Errors[n], prefix) {
+			t.Errorf("Errors[%d] = %q, want it to start with %q", n, o.Errors[n], prefix)
+		}
+	}
+	if _, err = CollectAll(context.Background(), hosts, testOptions(), true); err == nil {
+		t.Error("CollectAll with failFast succeeded, want an error")
+	}
+}
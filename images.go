@@ -0,0 +1,330 @@
+package fester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionInfo encapsulates version info extracted from a Docker image.
+type VersionInfo struct {
+	AppVersion string `json:"app_version" yaml:"app_version"`
+	GitRef     string `json:"git_ref" yaml:"git_ref"`
+	BuiltBy    string `json:"built_by" yaml:"built_by"`
+	ImageID    string `json:"image_id" yaml:"image_id"`
+	SourceURI  string `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// NewVersionInfo creates a new VersionInfo instance from info parsed out of a
+// []string.
+func NewVersionInfo(parsefrom []string, imageID string) *VersionInfo {
+	var appver, gitref, builtby string
+	for _, p := range parsefrom {
+		if strings.HasPrefix(p, "App-Version: ") {
+			appver = strings.TrimSpace(strings.TrimPrefix(p, "App-Version: "))
+		}
+		if strings.HasPrefix(p, "Git-Ref: ") {
+			gitref = strings.TrimSpace(strings.TrimPrefix(p, "Git-Ref: "))
+		}
+		if strings.HasPrefix(p, "Built-By: ") {
+			builtby = strings.TrimSpace(strings.TrimPrefix(p, "Built-By: "))
+		}
+	}
+	v := &VersionInfo{
+		AppVersion: appver,
+		GitRef:     gitref,
+		BuiltBy:    builtby,
+		ImageID:    imageID,
+	}
+	return v
+}
+
+// ImageSpecifier encapsulates a Docker image string.
+type ImageSpecifier struct {
+	Registry string
+	Image    string
+	Tag      string
+}
+
+// New returns a pointer to a new ImageSpecifier.
+func New(reg, img, tag string) *ImageSpecifier {
+	i := &ImageSpecifier{
+		Registry: reg,
+		Image:    img,
+		Tag:      tag,
+	}
+	return i
+}
+
+func (i *ImageSpecifier) String() string {
+	return fmt.Sprintf("%s/%s:%s", i.Registry, i.Image, i.Tag)
+}
+
+// ReadLines parses a []byte into a []string based on newlines.
+func ReadLines(content []byte) []string {
+	var lines []string
+	reader := bytes.NewReader(content)
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// ReadImages reads in file and returns a []string of image names.
+func ReadImages(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	filebytes, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return ReadLines(filebytes), nil
+}
+
+// Image contains the info reported by docker about an image on the host.
+type Image struct {
+	ID          string            `json:"Id" yaml:"Id"`
+	ParentID    string            `json:"ParentId" yaml:"ParentId"`
+	RepoTags    []string          `json:"RepoTags" yaml:"RepoTags"`
+	RepoDigests []string          `json:"RepoDigests" yaml:"RepoDigests"`
+	Created     int64             `json:"Created" yaml:"Created"`
+	Size        int64             `json:"Size" yaml:"Size"`
+	Labels      map[string]string `json:"Labels" yaml:"Labels"`
+	// Dangling is set for images without any tags, and InUse for images
+	// that one of the listed containers, running or not, was created from.
+	Dangling bool `json:"Dangling,omitempty" yaml:"Dangling,omitempty"`
+	InUse    bool `json:"InUse" yaml:"InUse"`
+	// History is only filled in when image history is collected.
+	History []*HistoryItem `json:"History,omitempty" yaml:"History,omitempty"`
+	// Platforms lists the image's platform variants and their digests. It's
+	// only filled in when the daemon uses the containerd image store, since
+	// the classic store holds a single platform per image.
+	Platforms []*ImagePlatform `json:"Platforms,omitempty" yaml:"Platforms,omitempty"`
+	SourceURI string           `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// HistoryItem is one layer in the build history of an image. ID is
+// "<missing>" for layers that were built elsewhere and pulled.
+type HistoryItem struct {
+	ID        string `json:"Id" yaml:"Id"`
+	Created   int64  `json:"Created" yaml:"Created"`
+	CreatedBy string `json:"CreatedBy" yaml:"CreatedBy"`
+	Size      int64  `json:"Size" yaml:"Size"`
+	Comment   string `json:"Comment" yaml:"Comment"`
+}
+
+// imageInspect is the subset of docker image inspect output used to build an
+// Image.
+type imageInspect struct {
+	ID          string `json:"Id"`
+	Parent      string
+	RepoTags    []string
+	RepoDigests []string
+	Created     time.Time
+	Size        int64
+	Config      struct {
+		Labels map[string]string
+	}
+}
+
+// newImage returns the *Image described by the inspect output.
+func newImage(i *imageInspect) *Image {
+	return &Image{
+		ID:          i.ID,
+		ParentID:    i.Parent,
+		RepoTags:    i.RepoTags,
+		RepoDigests: i.RepoDigests,
+		Created:     i.Created.Unix(),
+		Size:        i.Size,
+		Labels:      i.Config.Labels,
+		Dangling:    len(normalizeRefs(i.RepoTags)) == 0,
+	}
+}
+
+// noneRef is the placeholder docker shows for a missing repository, tag, or
+// digest, as in <none>:<none>.
+const noneRef = "<none>"
+
+// NormalizeTags removes the <none> placeholders and duplicates from the
+// RepoTags and RepoDigests of each of images, keeping them in order. An image
+// left without tags has an empty RepoTags, and is Dangling.
+func NormalizeTags(images []*Image) {
+	for _, i := range images {
+		i.RepoTags = normalizeRefs(i.RepoTags)
+		i.RepoDigests = normalizeRefs(i.RepoDigests)
+	}
+}
+
+// normalizeRefs returns refs without the ones containing noneRef or repeating
+// an earlier one.
+func normalizeRefs(refs []string) []string {
+	seen := make(map[string]bool)
+	kept := []string{}
+	for _, r := range refs {
+		if strings.Contains(r, noneRef) || seen[r] {
+			continue
+		}
+		seen[r] = true
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// ListImages returns the images on the Docker host that match filters,
+// including intermediate images.
+func (c *Client) ListImages(ctx context.Context, filters Filters) ([]*Image, error) {
+	var inspected []*imageInspect
+	args := append([]string{"--all", "--no-trunc"}, filters.Args()...)
+	if err := c.inspect(ctx, "image", &inspected, args...); err != nil {
+		return nil, err
+	}
+	images := []*Image{}
+	for _, i := range inspected {
+		images = append(images, newImage(i))
+	}
+	return images, nil
+}
+
+// InspectImage returns the image with the given ID or reference.
+func (c *Client) InspectImage(ctx context.Context, ref string) (*Image, error) {
+	var inspected []*imageInspect
+	if err := c.inspectJSON(ctx, &inspected, "image", "inspect", ref); err != nil {
+		return nil, err
+	}
+	if len(inspected) == 0 {
+		return nil, fmt.Errorf("no such image: %s", ref)
+	}
+	return newImage(inspected[0]), nil
+}
+
+// MissingDigests returns the IDs of the tagged images that have no repo
+// digests, which usually means they were built locally and never pushed.
+// Untagged images, such as intermediate build layers, are not considered.
+func MissingDigests(images []*Image) []string {
+	var ids []string
+	for _, i := range images {
+		if len(i.RepoTags) > 0 && len(i.RepoDigests) == 0 {
+			ids = append(ids, i.ID)
+		}
+	}
+	return ids
+}
+
+// ImagesInUse returns the images that one of containers was started from,
+// matching them up by ID.
+func ImagesInUse(images []*Image, containers []*Container) []*Image {
+	inUse := make(map[string]bool)
+	for _, c := range containers {
+		inUse[c.ImageID] = true
+	}
+	used := []*Image{}
+	for _, i := range images {
+		if inUse[i.ID] {
+			used = append(used, i)
+		}
+	}
+	return used
+}
+
+// FilterCreated returns the images created before before and after after.
+// A zero time leaves that side of the window open.
+func FilterCreated(images []*Image, before, after time.Time) []*Image {
+	if before.IsZero() && after.IsZero() {
+		return images
+	}
+	kept := []*Image{}
+	for _, i := range images {
+		created := time.Unix(i.Created, 0)
+		if !before.IsZero() && !created.Before(before) {
+			continue
+		}
+		if !after.IsZero() && !created.After(after) {
+			continue
+		}
+		kept = append(kept, i)
+	}
+	return kept
+}
+
+// MarkInUse sets InUse on each of images that one of containers was created
+// from, matching them up by ID and the host they came from.
+func MarkInUse(images []*Image, containers []*Container) {
+	inUse := make(map[[2]string]bool)
+	for _, c := range containers {
+		inUse[[2]string{c.SourceURI, c.ImageID}] = true
+	}
+	for _, i := range images {
+		i.InUse = inUse[[2]string{i.SourceURI, i.ID}]
+	}
+}
+
+// SortImages sorts images by their first tag, and then by ID. Untagged images
+// come first.
+func SortImages(images []*Image) {
+	sort.SliceStable(images, func(a, b int) bool {
+		ta, tb := firstOf(images[a].RepoTags), firstOf(images[b].RepoTags)
+		if ta != tb {
+			return ta < tb
+		}
+		return images[a].ID < images[b].ID
+	})
+}
+
+// firstOf returns the first of values, or "" if there are none.
+func firstOf(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// ImageHistory returns the build history of the image with the given ID, most
+// recent layer first.
+func (c *Client) ImageHistory(ctx context.Context, id string) ([]*HistoryItem, error) {
+	stdout, stderr, err := c.Output(ctx, "image", "history", "--no-trunc", "--human=false", "--format", "{{json .}}", id)
+	if err != nil {
+		return nil, err
+	}
+	logWarnings(stderr)
+	history := []*HistoryItem{}
+	for _, line := range ReadLines(stdout) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var h struct {
+			ID        string
+			CreatedAt time.Time
+			CreatedBy string
+			Size      string
+			Comment   string
+		}
+		if err = json.Unmarshal([]byte(line), &h); err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(h.Size, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing size %q: %s", h.Size, err)
+		}
+		history = append(history, &HistoryItem{
+			ID:        h.ID,
+			Created:   h.CreatedAt.Unix(),
+			CreatedBy: h.CreatedBy,
+			Size:      size,
+			Comment:   h.Comment,
+		})
+	}
+	return history, nil
+}
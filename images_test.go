@@ -0,0 +1,166 @@
+package fester
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name          string
+		tags, digests []string
+		wantTags      []string
+		wantDigests   []string
+	}{
+		{"tagged", []string{"redis:7", "redis:latest"}, []string{"redis@sha256:aaa"}, []string{"redis:7", "redis:latest"}, []string{"redis@sha256:aaa"}},
+		{"dangling", []string{"<none>:<none>"}, []string{"<none>@<none>"}, []string{}, []string{}},
+		{"nil", nil, nil, []string{}, []string{}},
+		{"none among real tags", []string{"<none>:<none>", "app:1.0"}, nil, []string{"app:1.0"}, []string{}},
+		{"a repository without a tag", []string{"app:<none>", "app:2.0"}, []string{"app@sha256:bbb"}, []string{"app:2.0"}, []string{"app@sha256:bbb"}},
+		{"duplicates keep their first place", []string{"b:1", "a:1", "b:1", "a:1"}, []string{"a@sha256:c", "a@sha256:c"}, []string{"b:1", "a:1"}, []string{"a@sha256:c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Image{RepoTags: tt.tags, RepoDigests: tt.digests}
+			NormalizeTags([]*Image{i})
+			if !reflect.DeepEqual(i.RepoTags, tt.wantTags) {
+				t.Errorf("RepoTags = %#v, want %#v", i.RepoTags, tt.wantTags)
+			}
+			if !reflect.DeepEqual(i.RepoDigests, tt.wantDigests) {
+				t.Errorf("RepoDigests = %#v, want %#v", i.RepoDigests, tt.wantDigests)
+			}
+		})
+	}
+}
+
+func TestDanglingImage(t *testing.T) {
+	tests := []struct {
+		tags     []string
+		dangling bool
+	}{
+		{[]string{"redis:7"}, false},
+		{[]string{"<none>:<none>"}, true},
+		{[]string{"<none>:<none>", "redis:7"}, false},
+		{nil, true},
+	}
+	for _, tt := range tests {
+		i := newImage(&imageInspect{ID: "sha256:aaa", RepoTags: tt.tags})
+		if i.Dangling != tt.dangling {
+			t.Errorf("newImage with tags %q: Dangling = %t, want %t", tt.tags, i.Dangling, tt.dangling)
+		}
+	}
+	// A dangling image is written with an explicit empty list of tags.
+	i := newImage(&imageInspect{ID: "sha256:aaa", RepoTags: []string{"<none>:<none>"}})
+	NormalizeTags([]*Image{i})
+	content, err := json.Marshal(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"RepoTags":[]`, `"Dangling":true`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s doesn't contain %s", content, want)
+		}
+	}
+}
+
+func TestCollectRawTags(t *testing.T) {
+	for _, raw := range []bool{false, true} {
+		opts := testOptions()
+		opts.RawTags = raw
+		o, err := Collect(context.Background(), &fakeDocker{images: testImages()}, opts)
+		if err != nil {
+			t.Fatalf("Collect: %s", err)
+		}
+		want := []string{}
+		if raw {
+			want = []string{"<none>:<none>"}
+		}
+		if got := findImage(o, "sha256:ccc").RepoTags; !reflect.DeepEqual(got, want) {
+			t.Errorf("with RawTags %t, the dangling image's RepoTags = %#v, want %#v", raw, got, want)
+		}
+	}
+}
+
+func TestMarkInUse(t *testing.T) {
+	// base is the parent of app, whose layers it shares; app has two tags,
+	// and debug is only used by a stopped container.
+	images := func() []*Image {
+		return []*Image{
+			{ID: "sha256:base", RepoTags: []string{"base:1"}, Size: 100},
+			{ID: "sha256:app", ParentID: "sha256:base", RepoTags: []string{"app:1.0", "app:latest"}, Size: 150},
+			{ID: "sha256:debug", RepoTags: []string{"debug:1"}, Size: 20},
+			{ID: "sha256:old", RepoTags: []string{}, Dangling: true, Size: 30},
+		}
+	}
+	tests := []struct {
+		name       string
+		containers []*Container
+		inUse      []string
+		unused     int
+		unusedSize int64
+	}{
+		{
+			name: "by ID, whichever tag the container was started from",
+			containers: []*Container{
+				{ID: "c1", Image: "app:1.0", ImageID: "sha256:app", State: "running"},
+				{ID: "c2", Image: "app:latest", ImageID: "sha256:app", State: "running"},
+				{ID: "c3", Image: "debug:1", ImageID: "sha256:debug", State: "exited"},
+			},
+			// The base isn't in use just because app was built on it.
+			inUse:      []string{"sha256:app", "sha256:debug"},
+			unused:     2,
+			unusedSize: 130,
+		},
+		{
+			name:       "no containers",
+			containers: []*Container{},
+			unused:     4,
+			unusedSize: 300,
+		},
+		{
+			name:       "a container whose image is gone",
+			containers: []*Container{{ID: "c1", ImageID: "sha256:deleted", State: "exited"}},
+			unused:     4,
+			unusedSize: 300,
+		},
+		{
+			name:       "a container on another host",
+			containers: []*Container{{ID: "c1", ImageID: "sha256:app", State: "running", SourceURI: "tcp://other:2376"}},
+			unused:     4,
+			unusedSize: 300,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := images()
+			MarkInUse(images, tt.containers)
+			var inUse []string
+			for _, i := range images {
+				if i.InUse {
+					inUse = append(inUse, i.ID)
+				}
+			}
+			if !reflect.DeepEqual(inUse, tt.inUse) {
+				t.Errorf("in use: %q, want %q", inUse, tt.inUse)
+			}
+			s := NewSummary(images, tt.containers)
+			if s.UnusedImageCount != tt.unused || s.UnusedImageSizeBytes != tt.unusedSize {
+				t.Errorf("UnusedImageCount = %d, UnusedImageSizeBytes = %d, want %d and %d", s.UnusedImageCount, s.UnusedImageSizeBytes, tt.unused, tt.unusedSize)
+			}
+			if s.TotalImageSizeBytes != 300 {
+				t.Errorf("TotalImageSizeBytes = %d, want 300", s.TotalImageSizeBytes)
+			}
+		})
+	}
+}
+
+func TestUnusedImagesWithoutContainers(t *testing.T) {
+	// When containers aren't listed, nothing is known to be unused.
+	s := NewSummary(testImages(), nil)
+	if s.UnusedImageCount != 0 || s.UnusedImageSizeBytes != 0 {
+		t.Errorf("UnusedImageCount = %d, UnusedImageSizeBytes = %d, want 0", s.UnusedImageCount, s.UnusedImageSizeBytes)
+	}
+}
@@ -0,0 +1,273 @@
+package fester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrRescan is returned by Update when an event can't be applied to a
+// manifest on its own, so the manifest has to be collected again.
+var ErrRescan = errors.New("the manifest has to be collected again")
+
+// Update applies e, an event reported by the daemon cli talks to, to the
+// manifest o that was collected with opts, looking up only the container or
+// image the event is about. uri is the URI of the daemon, recorded as the
+// source of the object if the manifest was aggregated from several hosts. The
+// summary is recomputed and the date set to now, but the other sections, such
+// as volumes and disk usage, are left as they were collected, as are the
+// excluded counts.
+//
+// ErrRescan is returned if the event can't be applied without collecting
+// everything again, for example because opts.RunningOnly ties the images to
+// the containers, or because the images are filtered by the daemon.
+func (o *OutputMap) Update(ctx context.Context, cli Docker, uri string, e *Event, opts Options) error {
+	if opts.RunningOnly {
+		return ErrRescan
+	}
+	source := ""
+	if len(o.Sources) > 0 {
+		source = uri
+	}
+	// As in Collect, the per-object calls share sem.
+	sem := newSemaphore(opts)
+	var err error
+	switch e.Type {
+	case "container":
+		if opts.SkipContainers {
+			return nil
+		}
+		err = o.updateContainer(ctx, cli, sem, source, e, opts)
+	case "image":
+		if opts.SkipImages {
+			return nil
+		}
+		err = o.updateImage(ctx, cli, sem, source, e, opts)
+	default:
+		err = ErrRescan
+	}
+	if err != nil {
+		return err
+	}
+	if opts.Sort {
+		SortImages(o.Images)
+		SortContainers(o.Containers)
+		for _, containers := range o.Projects {
+			SortContainers(containers)
+		}
+	}
+	o.updateSummary(opts)
+	if opts.DateFormat == "" {
+		opts.DateFormat = time.RFC3339
+	}
+	o.Date = NewTimestamp(time.Now(), opts.DateFormat, opts.UTC)
+	return nil
+}
+
+// updateContainer applies a container event, replacing the container with
+// its current state, or removing it if it was destroyed or no longer matches
+// opts. The per-object calls are made as sem allows.
+func (o *OutputMap) updateContainer(ctx context.Context, cli Docker, sem *semaphore.Weighted, source string, e *Event, opts Options) error {
+	o.removeContainer(source, e.ID)
+	if e.Action == "destroy" {
+		return nil
+	}
+	// Listing the container by ID along with the filters leaves it out if it
+	// doesn't match them, as a full listing would.
+	filters := Filters{"id": {e.ID}}
+	for k, v := range opts.ContainerFilters {
+		filters[k] = v
+	}
+	var containers []*Container
+	err := Retry(ctx, "listing containers", opts.Retries, opts.RetryInterval, func() (err error) {
+		containers, err = cli.ListContainers(ctx, filters)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("listing container %s: %s", e.ID, err)
+	}
+	containers, _ = ExcludeContainers(containers, opts.ExcludeContainers)
+	if opts.InspectContainers {
+		if err = inspectContainers(ctx, cli, sem, containers, opts); err != nil {
+			return err
+		}
+	}
+	if opts.Stats {
+		if err = containerStats(ctx, cli, sem, containers, opts); err != nil {
+			return err
+		}
+	}
+	if opts.ContainerLogs > 0 {
+		if err = containerLogs(ctx, cli, sem, containers, opts); err != nil {
+			return err
+		}
+	}
+	for _, c := range containers {
+		c.SourceURI = source
+		if o.Projects == nil {
+			o.Containers = append(o.Containers, c)
+			continue
+		}
+		project := c.Labels[ComposeProjectLabel]
+		if project == "" {
+			project = StandaloneProject
+		}
+		o.Projects[project] = append(o.Projects[project], c)
+	}
+	return nil
+}
+
+// removeContainer removes the container with the given ID from source,
+// whether it's listed in Containers or grouped into Projects.
+func (o *OutputMap) removeContainer(source, id string) {
+	keep := func(containers []*Container) []*Container {
+		kept := []*Container{}
+		for _, c := range containers {
+			if c.ID != id || c.SourceURI != source {
+				kept = append(kept, c)
+			}
+		}
+		return kept
+	}
+	o.Containers = keep(o.Containers)
+	for name, containers := range o.Projects {
+		if containers = keep(containers); len(containers) > 0 {
+			o.Projects[name] = containers
+		} else {
+			delete(o.Projects, name)
+		}
+	}
+}
+
+// updateImage applies an image event, replacing the image with its current
+// state and taking its tags from the images that had them, or removing it if
+// it was deleted or no longer matches opts. The history is fetched as sem
+// allows.
+func (o *OutputMap) updateImage(ctx context.Context, cli Docker, sem *semaphore.Weighted, source string, e *Event, opts Options) error {
+	if e.Action == "delete" {
+		o.removeImage(source, e.ID)
+		return nil
+	}
+	// The daemon applies the image filters when listing, and images can't
+	// be listed by ID.
+	if len(opts.ImageFilters) > 0 {
+		return ErrRescan
+	}
+	var image *Image
+	err := Retry(ctx, "inspecting image", opts.Retries, opts.RetryInterval, func() (err error) {
+		image, err = cli.InspectImage(ctx, e.ID)
+		return err
+	})
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "no such image") {
+		// Untagging an image's last tag deletes it.
+		o.removeImage(source, e.ID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("inspecting image %s: %s", e.ID, err)
+	}
+	o.removeImage(source, image.ID)
+	images := []*Image{image}
+	if !opts.RawTags {
+		NormalizeTags(images)
+	}
+	o.removeTags(source, image.RepoTags)
+	images = FilterRegistries(images, opts.ImageRegistries)
+	images = FilterCreated(images, opts.ImageCreatedBefore, opts.ImageCreatedAfter)
+	images, _ = ExcludeImages(images, opts.ExcludeImages)
+	if len(images) > 0 && o.containerdStore(source) {
+		platforms, err := cli.ImagePlatforms(ctx)
+		if err != nil {
+			slog.Warn("listing image platforms failed, leaving them out", "image", image.ID, "error", err)
+		}
+		AddPlatforms(images, platforms)
+	}
+	if opts.ImageHistory {
+		if err = imageHistories(ctx, cli, sem, images, opts); err != nil {
+			return err
+		}
+	}
+	for _, i := range images {
+		i.SourceURI = source
+		o.Images = append(o.Images, i)
+	}
+	return nil
+}
+
+// containerdStore returns true if the daemon source's images come from uses
+// the containerd image store, as recorded in its system info.
+func (o *OutputMap) containerdStore(source string) bool {
+	system := o.System
+	for _, s := range o.Sources {
+		if s.URI == source {
+			system = s.System
+		}
+	}
+	return system != nil && system.ContainerdSnapshotter
+}
+
+// removeImage removes the image with the given ID from source.
+func (o *OutputMap) removeImage(source, id string) {
+	kept := []*Image{}
+	for _, i := range o.Images {
+		if i.ID != id || i.SourceURI != source {
+			kept = append(kept, i)
+		}
+	}
+	o.Images = kept
+}
+
+// removeTags removes tags from the images from source, since tagging an
+// image moves the tag from the image that had it before. An image left
+// without tags is Dangling.
+func (o *OutputMap) removeTags(source string, tags []string) {
+	moved := make(map[string]bool)
+	for _, t := range tags {
+		moved[t] = true
+	}
+	for _, i := range o.Images {
+		if i.SourceURI != source {
+			continue
+		}
+		kept := []string{}
+		for _, t := range i.RepoTags {
+			if !moved[t] {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == len(i.RepoTags) {
+			continue
+		}
+		i.RepoTags = kept
+		i.Dangling = len(normalizeRefs(kept)) == 0
+	}
+}
+
+// updateSummary marks the images in use, promotes the container labels, and
+// recomputes the summary from the images and containers, keeping the excluded
+// counts.
+func (o *OutputMap) updateSummary(opts Options) {
+	containers := o.AllContainers()
+	if len(opts.PromoteLabels) > 0 {
+		o.Labels = PromoteLabels(opts.Labels, containers, opts.PromoteLabels)
+	}
+	MarkInUse(o.Images, containers)
+	s := NewSummary(o.Images, containers)
+	for _, u := range o.DiskUsage {
+		s.TotalReclaimableBytes += u.ReclaimableBytes
+	}
+	s.AddServices(o.Services, o.Tasks)
+	if opts.InspectContainers {
+		s.AddRestarts(containers, opts.RestartThreshold)
+	}
+	if o.Summary != nil {
+		s.ExcludedImageCount = o.Summary.ExcludedImageCount
+		s.ExcludedContainerCount = o.Summary.ExcludedContainerCount
+	}
+	o.Summary = s
+}
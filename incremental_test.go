@@ -0,0 +1,260 @@
+package fester
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// findImage returns the image in o with the given ID, or nil.
+func findImage(o *OutputMap, id string) *Image {
+	for _, i := range o.Images {
+		if i.ID == id {
+			return i
+		}
+	}
+	return nil
+}
+
+// findContainer returns the container in o with the given ID, or nil.
+func findContainer(o *OutputMap, id string) *Container {
+	for _, c := range o.AllContainers() {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// setTags sets the tags of the image with the given ID on the fake host.
+func setTags(f *fakeDocker, id string, tags ...string) {
+	for _, i := range f.images {
+		if i.ID == id {
+			i.RepoTags = tags
+			i.Dangling = len(tags) == 0
+		}
+	}
+}
+
+// removeFakeImage deletes the image with the given ID from the fake host.
+func removeFakeImage(f *fakeDocker, id string) {
+	kept := []*Image{}
+	for _, i := range f.images {
+		if i.ID != id {
+			kept = append(kept, i)
+		}
+	}
+	f.images = kept
+}
+
+func TestUpdate(t *testing.T) {
+	// step is a change on the host, followed by the event it's reported
+	// with.
+	type step struct {
+		change func(*fakeDocker)
+		event  Event
+	}
+	c3 := &Container{ID: "c3", Names: []string{"/web"}, ImageID: "sha256:bbb", State: "running"}
+	tests := []struct {
+		name  string
+		steps []step
+		check func(t *testing.T, o *OutputMap)
+	}{
+		{
+			name: "a tag moves from the image that had it",
+			steps: []step{{
+				change: func(f *fakeDocker) {
+					setTags(f, "sha256:aaa")
+					setTags(f, "sha256:bbb", "redis:7", "example.com/app:1.0")
+				},
+				event: Event{Type: "image", Action: "tag", ID: "sha256:bbb"},
+			}},
+			check: func(t *testing.T, o *OutputMap) {
+				if a := findImage(o, "sha256:aaa"); len(a.RepoTags) != 0 || !a.Dangling {
+					t.Errorf("aaa RepoTags = %v, Dangling = %t, want no tags and dangling", a.RepoTags, a.Dangling)
+				}
+				if b := findImage(o, "sha256:bbb"); !reflect.DeepEqual(b.RepoTags, []string{"redis:7", "example.com/app:1.0"}) {
+					t.Errorf("bbb RepoTags = %v", b.RepoTags)
+				}
+			},
+		},
+		{
+			name: "a tag moves and back again",
+			steps: []step{
+				{
+					change: func(f *fakeDocker) {
+						setTags(f, "sha256:bbb", "redis:7", "example.com/app:1.0")
+						setTags(f, "sha256:aaa")
+					},
+					event: Event{Type: "image", Action: "tag", ID: "sha256:bbb"},
+				},
+				{
+					change: func(f *fakeDocker) {
+						setTags(f, "sha256:aaa", "example.com/app:1.0")
+						setTags(f, "sha256:bbb", "redis:7")
+					},
+					event: Event{Type: "image", Action: "tag", ID: "sha256:aaa"},
+				},
+			},
+			check: func(t *testing.T, o *OutputMap) {
+				if a := findImage(o, "sha256:aaa"); !reflect.DeepEqual(a.RepoTags, []string{"example.com/app:1.0"}) || a.Dangling {
+					t.Errorf("aaa RepoTags = %v, Dangling = %t", a.RepoTags, a.Dangling)
+				}
+				if b := findImage(o, "sha256:bbb"); !reflect.DeepEqual(b.RepoTags, []string{"redis:7"}) {
+					t.Errorf("bbb RepoTags = %v", b.RepoTags)
+				}
+			},
+		},
+		{
+			name: "tag then untag",
+			steps: []step{
+				{
+					change: func(f *fakeDocker) { setTags(f, "sha256:bbb", "redis:7", "redis:latest") },
+					event:  Event{Type: "image", Action: "tag", ID: "sha256:bbb"},
+				},
+				{
+					change: func(f *fakeDocker) { setTags(f, "sha256:bbb", "redis:7") },
+					event:  Event{Type: "image", Action: "untag", ID: "sha256:bbb"},
+				},
+			},
+			check: func(t *testing.T, o *OutputMap) {
+				if b := findImage(o, "sha256:bbb"); !reflect.DeepEqual(b.RepoTags, []string{"redis:7"}) {
+					t.Errorf("bbb RepoTags = %v, want [redis:7]", b.RepoTags)
+				}
+			},
+		},
+		{
+			name: "untagging the last tag, then the delete",
+			steps: []step{
+				{
+					change: func(f *fakeDocker) { removeFakeImage(f, "sha256:bbb") },
+					event:  Event{Type: "image", Action: "untag", ID: "sha256:bbb"},
+				},
+				{event: Event{Type: "image", Action: "delete", ID: "sha256:bbb"}},
+			},
+			check: func(t *testing.T, o *OutputMap) {
+				if findImage(o, "sha256:bbb") != nil {
+					t.Error("bbb is still listed")
+				}
+				if o.Summary.ImageCount != 2 {
+					t.Errorf("ImageCount = %d, want 2", o.Summary.ImageCount)
+				}
+			},
+		},
+		{
+			name: "a tag reported after the image was deleted",
+			steps: []step{
+				{
+					change: func(f *fakeDocker) { removeFakeImage(f, "sha256:ccc") },
+					event:  Event{Type: "image", Action: "delete", ID: "sha256:ccc"},
+				},
+				{event: Event{Type: "image", Action: "tag", ID: "sha256:ccc"}},
+			},
+			check: func(t *testing.T, o *OutputMap) {
+				if findImage(o, "sha256:ccc") != nil {
+					t.Error("ccc is listed again")
+				}
+			},
+		},
+		{
+			name: "create then destroy",
+			steps: []step{
+				{
+					change: func(f *fakeDocker) { f.containers = append(f.containers, c3) },
+					event:  Event{Type: "container", Action: "create", ID: "c3"},
+				},
+				{
+					change: func(f *fakeDocker) { f.containers = f.containers[:2] },
+					event:  Event{Type: "container", Action: "destroy", ID: "c3"},
+				},
+			},
+			check: func(t *testing.T, o *OutputMap) {
+				if findContainer(o, "c3") != nil {
+					t.Error("c3 is still listed")
+				}
+				if b := findImage(o, "sha256:bbb"); b.InUse {
+					t.Error("bbb is in use after its container was destroyed")
+				}
+				if o.Summary.ContainerCount != 2 {
+					t.Errorf("ContainerCount = %d, want 2", o.Summary.ContainerCount)
+				}
+			},
+		},
+		{
+			name: "create",
+			steps: []step{{
+				change: func(f *fakeDocker) { f.containers = append(f.containers, c3) },
+				event:  Event{Type: "container", Action: "create", ID: "c3"},
+			}},
+			check: func(t *testing.T, o *OutputMap) {
+				if findContainer(o, "c3") == nil {
+					t.Fatal("c3 isn't listed")
+				}
+				if b := findImage(o, "sha256:bbb"); !b.InUse {
+					t.Error("bbb isn't in use by c3")
+				}
+				if o.Summary.ContainerCount != 3 || o.Summary.RunningContainerCount != 2 {
+					t.Errorf("ContainerCount = %d, RunningContainerCount = %d, want 3 and 2", o.Summary.ContainerCount, o.Summary.RunningContainerCount)
+				}
+			},
+		},
+		{
+			name: "a create reported after the container was destroyed",
+			steps: []step{
+				{event: Event{Type: "container", Action: "destroy", ID: "c2"}},
+				{event: Event{Type: "container", Action: "create", ID: "c2"}},
+			},
+			check: func(t *testing.T, o *OutputMap) {
+				// c2 is still on the fake host, so the late create
+				// lists it again, as a rescan would.
+				if findContainer(o, "c2") == nil {
+					t.Error("c2 isn't listed")
+				}
+			},
+		},
+		{
+			name:  "destroying an unknown container",
+			steps: []step{{event: Event{Type: "container", Action: "destroy", ID: "c9"}}},
+			check: func(t *testing.T, o *OutputMap) {
+				if o.Summary.ContainerCount != 2 {
+					t.Errorf("ContainerCount = %d, want 2", o.Summary.ContainerCount)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDocker{images: testImages(), containers: testContainers()}
+			ctx := context.Background()
+			o, err := Collect(ctx, fake, testOptions())
+			if err != nil {
+				t.Fatalf("Collect: %s", err)
+			}
+			for _, s := range tt.steps {
+				if s.change != nil {
+					s.change(fake)
+				}
+				if err := o.Update(ctx, fake, "", &s.event, testOptions()); err != nil {
+					t.Fatalf("Update(%s %s %s): %s", s.event.Type, s.event.Action, s.event.ID, err)
+				}
+			}
+			tt.check(t, o)
+		})
+	}
+}
+
+func TestUpdateRescans(t *testing.T) {
+	fake := &fakeDocker{images: testImages(), containers: testContainers()}
+	o, err := Collect(context.Background(), fake, testOptions())
+	if err != nil {
+		t.Fatalf("Collect: %s", err)
+	}
+	opts := testOptions()
+	opts.RunningOnly = true
+	if err := o.Update(context.Background(), fake, "", &Event{Type: "container", Action: "create", ID: "c1"}, opts); err != ErrRescan {
+		t.Errorf("Update with RunningOnly = %v, want ErrRescan", err)
+	}
+	if err := o.Update(context.Background(), fake, "", &Event{Type: "network", Action: "create", ID: "n1"}, testOptions()); err != ErrRescan {
+		t.Errorf("Update of a network event = %v, want ErrRescan", err)
+	}
+}
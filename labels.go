@@ -0,0 +1,47 @@
+package fester
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// PromoteLabels returns a copy of labels with each of keys, a container label
+// key, added with the value the containers carrying that label give it. If
+// they disagree, every value they give is recorded, sorted and joined with
+// commas, and a warning is logged. A key that's in labels already, or that no
+// container carries, is left alone.
+func PromoteLabels(labels map[string]string, containers []*Container, keys []string) map[string]string {
+	if len(keys) == 0 {
+		return labels
+	}
+	promoted := make(map[string]string, len(labels)+len(keys))
+	for k, v := range labels {
+		promoted[k] = v
+	}
+	for _, key := range keys {
+		if _, ok := labels[key]; ok {
+			continue
+		}
+		seen := map[string]bool{}
+		var values []string
+		for _, c := range containers {
+			if v, ok := c.Labels[key]; ok && !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) > 1 {
+			sort.Strings(values)
+			slog.Warn("containers disagree on a promoted label, recording every value", "label", key, "values", values)
+		}
+		promoted[key] = strings.Join(values, ",")
+	}
+	if len(promoted) == 0 {
+		return nil
+	}
+	return promoted
+}
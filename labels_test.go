@@ -0,0 +1,54 @@
+package fester
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPromoteLabels(t *testing.T) {
+	role := "com.example.role"
+	containers := func(values ...string) []*Container {
+		var cs []*Container
+		for _, v := range values {
+			cs = append(cs, &Container{Labels: map[string]string{role: v}})
+		}
+		// A container without the label doesn't count.
+		return append(cs, &Container{Labels: map[string]string{"other": "x"}})
+	}
+	tests := []struct {
+		name       string
+		labels     map[string]string
+		containers []*Container
+		keys       []string
+		want       map[string]string
+	}{
+		{"no keys", map[string]string{"env": "prod"}, containers("db"), nil, map[string]string{"env": "prod"}},
+		{"no keys or labels", nil, containers("db"), nil, nil},
+		{"one container", map[string]string{"env": "prod"}, containers("db"), []string{role}, map[string]string{"env": "prod", role: "db"}},
+		{"containers agree", nil, containers("db", "db"), []string{role}, map[string]string{role: "db"}},
+		{"containers disagree", nil, containers("web", "db", "web", "cache"), []string{role}, map[string]string{role: "cache,db,web"}},
+		{"an empty value", nil, containers(""), []string{role}, map[string]string{role: ""}},
+		{"no container carries the key", map[string]string{"env": "prod"}, containers(), []string{role}, map[string]string{"env": "prod"}},
+		{"nothing to promote and no labels", nil, containers(), []string{role}, nil},
+		{"an explicit label wins", map[string]string{role: "set"}, containers("db"), []string{role}, map[string]string{role: "set"}},
+		{"several keys", nil, append(containers("db"), &Container{Labels: map[string]string{"tier": "back"}}), []string{role, "tier", "missing"}, map[string]string{role: "db", "tier": "back"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before map[string]string
+			if tt.labels != nil {
+				before = map[string]string{}
+				for k, v := range tt.labels {
+					before[k] = v
+				}
+			}
+			got := PromoteLabels(tt.labels, tt.containers, tt.keys)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PromoteLabels = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.labels, before) {
+				t.Errorf("PromoteLabels changed the labels it was given to %v", tt.labels)
+			}
+		})
+	}
+}
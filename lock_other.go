@@ -0,0 +1,11 @@
+//go:build !unix
+
+package fester
+
+import "os"
+
+// lockFile does nothing where advisory locks aren't available. Appends are
+// still atomic for small writes on most systems.
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,55 @@
+package fester
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// LogsTruncatedMarker is appended to a container's logs when they were cut
+// short to stay under the limit on captured log bytes.
+const LogsTruncatedMarker = "\n[truncated by fester]\n"
+
+// ContainerLogs returns the last tail lines the container with the given ID
+// logged. Its stdout and stderr are captured together, in the order docker
+// writes them.
+func (c *Client) ContainerLogs(ctx context.Context, id string, tail int) (string, error) {
+	var buf bytes.Buffer
+	cmd := c.Command(ctx, "container", "logs", "--tail", strconv.Itoa(tail), id)
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(buf.String()); msg != "" {
+			err = fmt.Errorf("%s: %s", err, msg)
+		}
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// limitLogs truncates the logs of containers, in order, so that no more than
+// maxBytes of them are kept in total. Logs are cut at the start of a UTF-8
+// character, so a character is never split, and truncated logs end with
+// LogsTruncatedMarker. A maxBytes of zero or less means no limit.
+func limitLogs(containers []*Container, maxBytes int64) {
+	if maxBytes <= 0 {
+		return
+	}
+	left := maxBytes
+	for _, c := range containers {
+		if int64(len(c.Logs)) <= left {
+			left -= int64(len(c.Logs))
+			continue
+		}
+		n := int(left)
+		for n > 0 && !utf8.RuneStart(c.Logs[n]) {
+			n--
+		}
+		c.Logs = c.Logs[:n] + LogsTruncatedMarker
+		c.LogsTruncated = true
+		left = 0
+	}
+}
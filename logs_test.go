@@ -0,0 +1,46 @@
+package fester
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLimitLogs(t *testing.T) {
+	tests := []struct {
+		name     string
+		logs     []string
+		maxBytes int64
+		want     []string
+	}{
+		{"no limit", []string{"hello\n"}, 0, []string{"hello\n"}},
+		{"under the limit", []string{"abc", "def"}, 6, []string{"abc", "def"}},
+		{"the limit is shared", []string{"abcd", "efgh", "ijkl"}, 6, []string{"abcd", "ef" + LogsTruncatedMarker, LogsTruncatedMarker}},
+		{"ASCII is cut at the limit", []string{"abcdef"}, 3, []string{"abc" + LogsTruncatedMarker}},
+		// é is 2 bytes and 日 is 3, so a cut at 2 or 3 bytes falls inside them.
+		{"a 2-byte character isn't split", []string{"aéz"}, 2, []string{"a" + LogsTruncatedMarker}},
+		{"a 3-byte character isn't split", []string{"a日z"}, 3, []string{"a" + LogsTruncatedMarker}},
+		{"a cut after a character", []string{"a日z"}, 4, []string{"a日" + LogsTruncatedMarker}},
+		{"a character at the start", []string{"日本"}, 2, []string{LogsTruncatedMarker}},
+		{"a 4-byte character isn't split", []string{"x🐳"}, 4, []string{"x" + LogsTruncatedMarker}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var containers []*Container
+			for _, l := range tt.logs {
+				containers = append(containers, &Container{Logs: l})
+			}
+			limitLogs(containers, tt.maxBytes)
+			for n, c := range containers {
+				if c.Logs != tt.want[n] {
+					t.Errorf("container %d Logs = %q, want %q", n, c.Logs, tt.want[n])
+				}
+				if !utf8.ValidString(c.Logs) {
+					t.Errorf("container %d Logs %q aren't valid UTF-8", n, c.Logs)
+				}
+				if truncated := c.Logs != tt.logs[n]; c.LogsTruncated != truncated {
+					t.Errorf("container %d LogsTruncated = %t, want %t", n, c.LogsTruncated, truncated)
+				}
+			}
+		})
+	}
+}
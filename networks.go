@@ -0,0 +1,43 @@
+package fester
+
+import "context"
+
+// NetworkIPAMConfig is a single IPAM address pool of a network.
+type NetworkIPAMConfig struct {
+	Subnet  string `json:"Subnet,omitempty" yaml:"Subnet,omitempty"`
+	IPRange string `json:"IPRange,omitempty" yaml:"IPRange,omitempty"`
+	Gateway string `json:"Gateway,omitempty" yaml:"Gateway,omitempty"`
+}
+
+// NetworkIPAM is the IP address management configuration of a network.
+type NetworkIPAM struct {
+	Driver  string               `json:"Driver" yaml:"Driver"`
+	Options map[string]string    `json:"Options" yaml:"Options"`
+	Config  []*NetworkIPAMConfig `json:"Config" yaml:"Config"`
+}
+
+// Network contains the info reported by docker about a network.
+type Network struct {
+	Name       string            `json:"Name" yaml:"Name"`
+	ID         string            `json:"Id" yaml:"Id"`
+	Created    string            `json:"Created" yaml:"Created"`
+	Scope      string            `json:"Scope" yaml:"Scope"`
+	Driver     string            `json:"Driver" yaml:"Driver"`
+	EnableIPv6 bool              `json:"EnableIPv6" yaml:"EnableIPv6"`
+	IPAM       NetworkIPAM       `json:"IPAM" yaml:"IPAM"`
+	Internal   bool              `json:"Internal" yaml:"Internal"`
+	Attachable bool              `json:"Attachable" yaml:"Attachable"`
+	Options    map[string]string `json:"Options" yaml:"Options"`
+	Labels     map[string]string `json:"Labels" yaml:"Labels"`
+	SourceURI  string            `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// ListNetworks returns all of the networks known to the Docker daemon,
+// including the built-in bridge, host, and none networks.
+func (c *Client) ListNetworks(ctx context.Context) ([]*Network, error) {
+	networks := []*Network{}
+	if err := c.inspect(ctx, "network", &networks, "--no-trunc"); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
@@ -0,0 +1,61 @@
+package fester
+
+import "strings"
+
+// The OCI standard annotations that images commonly carry as labels, stamped
+// at build time: the revision of the source the image was built from, and
+// when it was built, as an RFC3339 date.
+const (
+	OCIRevisionLabel = "org.opencontainers.image.revision"
+	OCICreatedLabel  = "org.opencontainers.image.created"
+)
+
+// ImageBuild is the source revision and build date an image's OCI labels
+// give it. Either may be empty if the image doesn't carry that label.
+type ImageBuild struct {
+	ID       string `json:"id" yaml:"id"`
+	Image    string `json:"image,omitempty" yaml:"image,omitempty"`
+	Revision string `json:"revision,omitempty" yaml:"revision,omitempty"`
+	Created  string `json:"created,omitempty" yaml:"created,omitempty"`
+}
+
+// ociLabel returns the value of the OCI label key in labels, or "" if it's
+// missing or blank.
+func ociLabel(labels map[string]string, key string) string {
+	return strings.TrimSpace(labels[key])
+}
+
+// ImageBuilds returns the revision and build date of each of the images that
+// carries at least one of the OCI labels for them, in the same order. Images
+// are named by their first tag, if they have one.
+func ImageBuilds(images []*Image) []*ImageBuild {
+	var builds []*ImageBuild
+	for _, i := range images {
+		b := &ImageBuild{
+			ID:       i.ID,
+			Image:    firstOf(i.RepoTags),
+			Revision: ociLabel(i.Labels, OCIRevisionLabel),
+			Created:  ociLabel(i.Labels, OCICreatedLabel),
+		}
+		if b.Revision != "" || b.Created != "" {
+			builds = append(builds, b)
+		}
+	}
+	return builds
+}
+
+// MissingOCILabels returns the IDs of the images that aren't dangling but
+// lack the OCI revision or created label. Dangling images, such as
+// intermediate build layers, are not considered.
+func MissingOCILabels(images []*Image) []string {
+	var ids []string
+	for _, i := range images {
+		if i.Dangling || len(i.RepoTags) == 0 {
+			continue
+		}
+		if ociLabel(i.Labels, OCIRevisionLabel) == "" || ociLabel(i.Labels, OCICreatedLabel) == "" {
+			ids = append(ids, i.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,61 @@
+package fester
+
+import (
+	"reflect"
+	"testing"
+)
+
+// ociImages returns images carrying every combination of the OCI labels.
+func ociImages() []*Image {
+	return []*Image{
+		{ID: "sha256:both", RepoTags: []string{"app:1.0", "app:latest"}, Labels: map[string]string{
+			OCIRevisionLabel: "abc123",
+			OCICreatedLabel:  "2024-05-01T12:00:00Z",
+			"maintainer":     "ops",
+		}},
+		{ID: "sha256:revision", RepoTags: []string{"worker:2"}, Labels: map[string]string{OCIRevisionLabel: "def456"}},
+		{ID: "sha256:created", RepoTags: []string{"cron:3"}, Labels: map[string]string{OCICreatedLabel: "2024-06-01T00:00:00Z"}},
+		{ID: "sha256:blank", RepoTags: []string{"blank:1"}, Labels: map[string]string{OCIRevisionLabel: "  ", OCICreatedLabel: ""}},
+		{ID: "sha256:none", RepoTags: []string{"redis:7"}},
+		{ID: "sha256:dangling", RepoTags: []string{}, Dangling: true},
+		{ID: "sha256:untagged-build", RepoTags: []string{}, Dangling: true, Labels: map[string]string{OCIRevisionLabel: "fff000"}},
+	}
+}
+
+func TestImageBuilds(t *testing.T) {
+	want := []*ImageBuild{
+		{ID: "sha256:both", Image: "app:1.0", Revision: "abc123", Created: "2024-05-01T12:00:00Z"},
+		{ID: "sha256:revision", Image: "worker:2", Revision: "def456"},
+		{ID: "sha256:created", Image: "cron:3", Created: "2024-06-01T00:00:00Z"},
+		{ID: "sha256:untagged-build", Revision: "fff000"},
+	}
+	got := ImageBuilds(ociImages())
+	if len(got) != len(want) {
+		t.Fatalf("ImageBuilds returned %d builds, want %d", len(got), len(want))
+	}
+	for n := range want {
+		if !reflect.DeepEqual(got[n], want[n]) {
+			t.Errorf("build %d = %+v, want %+v", n, got[n], want[n])
+		}
+	}
+	if got = ImageBuilds(nil); got != nil {
+		t.Errorf("ImageBuilds(nil) = %v, want nil", got)
+	}
+}
+
+func TestMissingOCILabels(t *testing.T) {
+	want := []string{"sha256:revision", "sha256:created", "sha256:blank", "sha256:none"}
+	if got := MissingOCILabels(ociImages()); !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingOCILabels = %q, want %q", got, want)
+	}
+	if got := MissingOCILabels(ociImages()[:1]); got != nil {
+		t.Errorf("MissingOCILabels of a labelled image = %q, want none", got)
+	}
+}
+
+func TestSummaryBuilds(t *testing.T) {
+	s := NewSummary(ociImages(), nil)
+	if len(s.Builds) != 4 || s.Builds[0].Revision != "abc123" {
+		t.Errorf("Summary.Builds = %v", s.Builds)
+	}
+}
@@ -0,0 +1,490 @@
+package fester
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{"json", "yaml", "ndjson"}
+
+// ValidFormat returns true if format is one of Formats.
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Marshal encodes the OutputMap in the given format. JSON is indented with
+// indent spaces, or a tab if indent is negative, and written compactly if
+// indent is zero. indent has no effect on the other formats.
+func (o *OutputMap) Marshal(format string, indent int) ([]byte, error) {
+	if format == "ndjson" {
+		return o.marshalNDJSON()
+	}
+	return marshal(o, format, indent)
+}
+
+// timeoutMarker is written in place of a section that ran out of time.
+var timeoutMarker = map[string]bool{"timeout": true}
+
+// sectionFields maps the sections that fill in top-level fields of the
+// manifest to those fields. The others, such as stats, fill in a field of
+// each container or image, so they are only named in TimedOut.
+var sectionFields = map[string][]string{
+	SectionSystemInfo: {"system"},
+	SectionDiskUsage:  {"disk_usage"},
+	SectionPlugins:    {"plugins"},
+	SectionSwarm:      {"services", "tasks"},
+}
+
+// timeoutFields returns the fields to write timeoutMarker in place of: those
+// of the sections in TimedOut that were left empty. A section of an
+// aggregated manifest that only timed out on some of the hosts keeps what the
+// others reported, and the system info of its hosts is kept in Sources, so
+// it's never marked.
+func (o *OutputMap) timeoutFields() map[string]bool {
+	empty := map[string]bool{
+		"system":     o.System == nil && len(o.Sources) == 0,
+		"disk_usage": len(o.DiskUsage) == 0,
+		"plugins":    len(o.Plugins) == 0,
+		"services":   len(o.Services) == 0,
+		"tasks":      len(o.Tasks) == 0,
+	}
+	fields := map[string]bool{}
+	for _, section := range o.TimedOut {
+		for _, f := range sectionFields[section] {
+			if empty[f] {
+				fields[f] = true
+			}
+		}
+	}
+	return fields
+}
+
+// fieldNames returns the names the fields of OutputMap are encoded with by
+// the encoding named by tag, json or yaml, in order.
+func fieldNames(tag string) []string {
+	t := reflect.TypeOf(OutputMap{})
+	names := make([]string, t.NumField())
+	for n := range names {
+		names[n], _, _ = strings.Cut(t.Field(n).Tag.Get(tag), ",")
+	}
+	return names
+}
+
+// isTimeoutMarker returns true if raw is timeoutMarker encoded as JSON.
+func isTimeoutMarker(raw json.RawMessage) bool {
+	var v map[string]interface{}
+	return json.Unmarshal(raw, &v) == nil && len(v) == 1 && v["timeout"] == true
+}
+
+// MarshalJSON implements json.Marshaler, writing timeoutMarker in place of
+// the sections that ran out of time.
+func (o *OutputMap) MarshalJSON() ([]byte, error) {
+	type outputMap OutputMap
+	content, err := json.Marshal((*outputMap)(o))
+	markers := o.timeoutFields()
+	if err != nil || len(markers) == 0 {
+		return content, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	marker, err := json.Marshal(timeoutMarker)
+	if err != nil {
+		return nil, err
+	}
+	// The fields are written back in the order of OutputMap, with the
+	// markers where the sections would have been.
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range fieldNames("json") {
+		value, ok := fields[name]
+		if markers[name] {
+			value, ok = marker, true
+		}
+		if !ok {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, leaving the sections that were
+// written as timeoutMarker empty.
+func (o *OutputMap) UnmarshalJSON(content []byte) error {
+	type outputMap OutputMap
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return err
+	}
+	for name, value := range fields {
+		if isTimeoutMarker(value) {
+			delete(fields, name)
+		}
+	}
+	content, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, (*outputMap)(o))
+}
+
+// MarshalYAML implements yaml.Marshaler, leaving out Images, Containers, and
+// Volumes if they weren't collected, as their omitzero tags do for JSON, and
+// writing timeoutMarker in place of the sections that ran out of time.
+func (o *OutputMap) MarshalYAML() (interface{}, error) {
+	type outputMap OutputMap
+	var n yaml.Node
+	if err := n.Encode((*outputMap)(o)); err != nil {
+		return nil, err
+	}
+	keys, values := map[string]*yaml.Node{}, map[string]*yaml.Node{}
+	for k := 0; k+1 < len(n.Content); k += 2 {
+		keys[n.Content[k].Value], values[n.Content[k].Value] = n.Content[k], n.Content[k+1]
+	}
+	markers := o.timeoutFields()
+	var kept []*yaml.Node
+	for _, name := range fieldNames("yaml") {
+		switch {
+		case name == "images" && o.Images == nil,
+			name == "containers" && o.Containers == nil,
+			name == "volumes" && o.Volumes == nil:
+			continue
+		case markers[name]:
+			var marker yaml.Node
+			if err := marker.Encode(timeoutMarker); err != nil {
+				return nil, err
+			}
+			values[name] = &marker
+			if keys[name] == nil {
+				keys[name] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+			}
+		}
+		if value, ok := values[name]; ok {
+			kept = append(kept, keys[name], value)
+		}
+	}
+	n.Content = kept
+	return &n, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, leaving the sections that were
+// written as timeoutMarker empty.
+func (o *OutputMap) UnmarshalYAML(n *yaml.Node) error {
+	type outputMap OutputMap
+	if n.Kind == yaml.MappingNode {
+		var kept []*yaml.Node
+		for k := 0; k+1 < len(n.Content); k += 2 {
+			var marker map[string]interface{}
+			value := n.Content[k+1]
+			if value.Kind == yaml.MappingNode && value.Decode(&marker) == nil && len(marker) == 1 && marker["timeout"] == true {
+				continue
+			}
+			kept = append(kept, n.Content[k], value)
+		}
+		n.Content = kept
+	}
+	return n.Decode((*outputMap)(o))
+}
+
+// marshal encodes v as JSON or YAML, indenting JSON as Marshal does.
+func marshal(v interface{}, format string, indent int) ([]byte, error) {
+	switch format {
+	case "json":
+		switch {
+		case indent == 0:
+			return json.Marshal(v)
+		case indent < 0:
+			return json.MarshalIndent(v, "", "\t")
+		}
+		return json.MarshalIndent(v, "", strings.Repeat(" ", indent))
+	case "yaml":
+		return yaml.Marshal(v)
+	}
+	return nil, fmt.Errorf("unknown format %q, must be one of: %s", format, strings.Join(Formats, ", "))
+}
+
+// marshalNDJSON encodes the OutputMap as newline-delimited JSON. The first line
+// is a "metadata" record holding the top-level fields, such as the labels,
+// sources, system info, disk usage, and summary, followed by one line per
+// pulled image, image, container, volume, network, plugin, service, task, and
+// file. Every line carries a "type" field and the hostname and date of the
+// manifest.
+func (o *OutputMap) marshalNDJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	write := func(recordType string, item interface{}) error {
+		record := map[string]interface{}{}
+		if item != nil {
+			b, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if err = json.Unmarshal(b, &record); err != nil {
+				return err
+			}
+		}
+		record["type"] = recordType
+		record["hostname"] = o.Hostname
+		record["date"] = o.Date
+		return enc.Encode(record)
+	}
+	metadata := map[string]interface{}{
+		"schema_version":     o.SchemaVersion,
+		"fester_version":     o.FesterVersion,
+		"docker_api_version": o.DockerAPIVersion,
+		"summary":            o.Summary,
+	}
+	if len(o.TimedOut) > 0 {
+		metadata["timed_out"] = o.TimedOut
+	}
+	if len(o.Errors) > 0 {
+		metadata["errors"] = o.Errors
+	}
+	if len(o.Timings) > 0 {
+		metadata["timings"] = o.Timings
+	}
+	if len(o.Labels) > 0 {
+		metadata["labels"] = o.Labels
+	}
+	if len(o.Sources) > 0 {
+		metadata["sources"] = o.Sources
+	}
+	if o.System != nil {
+		metadata["system"] = o.System
+	}
+	if len(o.DiskUsage) > 0 {
+		metadata["disk_usage"] = o.DiskUsage
+	}
+	if err := write("metadata", metadata); err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range o.DockerImages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range o.DockerImages[name] {
+			image := struct {
+				Name string `json:"name"`
+				*VersionInfo
+			}{name, v}
+			if err := write("docker_image", image); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, i := range o.Images {
+		if err := write("image", i); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range o.AllContainers() {
+		if err := write("container", c); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range o.Volumes {
+		if err := write("volume", v); err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range o.Networks {
+		if err := write("network", n); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range o.Plugins {
+		if err := write("plugin", p); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range o.Services {
+		if err := write("service", s); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range o.Tasks {
+		if err := write("task", t); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range o.Files {
+		if err := write("file", f); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Checksum returns the SHA-256 checksum of content in the format sha256sum
+// writes, as the checksum of the file name.
+func Checksum(content []byte, name string) []byte {
+	sum := sha256.Sum256(content)
+	return []byte(hex.EncodeToString(sum[:]) + "  " + name + "\n")
+}
+
+// Gzip returns content compressed with gzip.
+func Gzip(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExpandPath replaces the {hostname}, {date}, and {unix} placeholders in path
+// like ExpandKey does, except that the characters in the hostname and date
+// that don't belong in a file name, such as the colons in an RFC3339 date,
+// are replaced with dashes.
+func ExpandPath(path string, o *OutputMap) string {
+	return strings.NewReplacer(
+		"{hostname}", safeName(o.Hostname),
+		"{date}", safeName(o.Date.String()),
+		"{unix}", strconv.FormatInt(o.Date.Unix(), 10),
+	).Replace(path)
+}
+
+// safeName returns s with every character other than letters, digits, dots,
+// dashes, underscores, and pluses replaced with a dash.
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("._+-", r):
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// FileMode is the mode of the files WriteOutput, TeeOutput, and AppendOutput
+// create, and DirMode the mode of the directories they create. Both are set
+// exactly, whatever the umask.
+var (
+	FileMode os.FileMode = 0644
+	DirMode  os.FileMode = 0755
+)
+
+// mkdirAll creates dir and any of its parents that don't exist yet with
+// DirMode, like os.MkdirAll does, except that the umask doesn't apply.
+func mkdirAll(dir string) error {
+	var created []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		created = append(created, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return err
+	}
+	for _, d := range created {
+		if err := os.Chmod(d, DirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMode returns an error if the file at path doesn't have the permissions
+// in mode. Windows doesn't have Unix permissions, so it isn't checked there.
+func checkMode(path string, mode os.FileMode) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if got := info.Mode().Perm(); got != mode.Perm() {
+		return fmt.Errorf("%s has mode %#o rather than %#o", path, got, mode.Perm())
+	}
+	return nil
+}
+
+// WriteOutput writes content to the file at path, or to stdout if path is
+// empty. Parent directories are created as needed. The content is written to a
+// temporary file in the same directory and then renamed into place so that an
+// existing file is never left half-written. The file ends up with FileMode.
+func WriteOutput(path string, content []byte) error {
+	return TeeOutput(path, content, nil)
+}
+
+// TeeOutput writes content to path like WriteOutput does, and also to tee if
+// it isn't nil. Both are written through the same io.MultiWriter, so they get
+// identical bytes.
+func TeeOutput(path string, content []byte, tee io.Writer) error {
+	if path == "" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := mkdirAll(dir); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	var w io.Writer = tmp
+	if tee != nil {
+		w = io.MultiWriter(tmp, tee)
+	}
+	if _, err = w.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), FileMode); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return checkMode(path, FileMode)
+}
@@ -0,0 +1,262 @@
+package fester
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// timedOutManifest returns a manifest collected from the fake host in which
+// disk usage and the Swarm ran out of time.
+func timedOutManifest(t *testing.T) *OutputMap {
+	o, err := Collect(context.Background(), &fakeDocker{images: testImages(), containers: testContainers()}, testOptions())
+	if err != nil {
+		t.Fatalf("Collect: %s", err)
+	}
+	o.TimedOut = []string{SectionDiskUsage, SectionSwarm}
+	return o
+}
+
+func TestTimeoutMarkers(t *testing.T) {
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			o := timedOutManifest(t)
+			content, err := o.Marshal(format, 2)
+			if err != nil {
+				t.Fatalf("Marshal: %s", err)
+			}
+			var markers []string
+			if format == "json" {
+				markers = []string{`"disk_usage": {` + "\n" + `    "timeout": true`, `"services": {`, `"tasks": {`}
+			} else {
+				markers = []string{"disk_usage:\n    timeout: true", "services:\n    timeout: true", "tasks:\n    timeout: true"}
+			}
+			for _, m := range markers {
+				if !bytes.Contains(content, []byte(m)) {
+					t.Errorf("%s doesn't contain %q:\n%s", format, m, content)
+				}
+			}
+			if bytes.Contains(content, []byte("plugins")) {
+				t.Errorf("plugins, which didn't time out, is in the %s:\n%s", format, content)
+			}
+			// The markers are where the sections would have been.
+			key := func(name string) int {
+				if format == "json" {
+					return strings.Index(string(content), `"`+name+`": `)
+				}
+				return strings.Index(string(content), "\n"+name+":")
+			}
+			if !(key("system") < key("disk_usage") && key("disk_usage") < key("files")) {
+				t.Errorf("disk_usage is out of place:\n%s", content)
+			}
+			parsed, err := parseManifest("manifest."+format, content)
+			if err != nil {
+				t.Fatalf("parsing the manifest back: %s", err)
+			}
+			if parsed.DiskUsage != nil || parsed.Services != nil || parsed.Tasks != nil {
+				t.Errorf("parsed the markers as %v, %v, %v", parsed.DiskUsage, parsed.Services, parsed.Tasks)
+			}
+			if len(parsed.TimedOut) != 2 || len(parsed.Images) != 3 || parsed.Hostname != "test-host" {
+				t.Errorf("parsed TimedOut = %v, %d images, hostname %q", parsed.TimedOut, len(parsed.Images), parsed.Hostname)
+			}
+		})
+	}
+}
+
+func TestTimeoutMarkersValidate(t *testing.T) {
+	o := timedOutManifest(t)
+	o.TimedOut = append(o.TimedOut, SectionSystemInfo, SectionPlugins)
+	o.System = nil
+	if err := ValidateManifest(o); err != nil {
+		t.Errorf("a manifest with timeout markers doesn't validate: %s", err)
+	}
+}
+
+func TestTimeoutMarkersKeepCollectedSections(t *testing.T) {
+	o := timedOutManifest(t)
+	// In an aggregated manifest, a section can time out on one host and
+	// not another.
+	o.DiskUsage = []*DiskUsage{{Type: "Images"}}
+	content, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte(`"disk_usage":{"timeout":true}`)) {
+		t.Errorf("disk_usage was replaced with a marker:\n%s", content)
+	}
+	if !bytes.Contains(content, []byte(`"services":{"timeout":true}`)) {
+		t.Errorf("services wasn't replaced with a marker:\n%s", content)
+	}
+}
+
+func TestTimeoutMarkersSplit(t *testing.T) {
+	sections := timedOutManifest(t).Split()
+	for _, name := range []string{"disk_usage", "services", "tasks"} {
+		if sections[name] == nil || sections[name][name] == nil {
+			t.Errorf("there's no %s section with a marker", name)
+		}
+	}
+	if sections["plugins"] != nil {
+		t.Error("there's a plugins section")
+	}
+}
+
+func TestMarshalWithoutTimeouts(t *testing.T) {
+	o, err := Collect(context.Background(), &fakeDocker{images: testImages(), containers: testContainers()}, testOptions())
+	if err != nil {
+		t.Fatalf("Collect: %s", err)
+	}
+	type outputMap OutputMap
+	want, err := json.Marshal((*outputMap)(o))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("json.Marshal = %s, want %s", got, want)
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	o := &OutputMap{Hostname: "test-host", Networks: []*Network{}}
+	tests := []struct {
+		name   string
+		format string
+		indent int
+		want   string
+	}{
+		{"two spaces", "json", 2, "{\n  \"schema_version\": 0,\n"},
+		{"four spaces", "json", 4, "{\n    \"schema_version\": 0,\n"},
+		{"compact", "json", 0, `{"schema_version":0,"fester_version":"","hostname":"test-host",`},
+		{"tabs", "json", -1, "{\n\t\"schema_version\": 0,\n"},
+		{"tabs however negative", "json", -8, "{\n\t\"schema_version\": 0,\n"},
+		{"YAML ignores indent", "yaml", 0, "schema_version: 0\nfester_version: \"\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := o.Marshal(tt.format, tt.indent)
+			if err != nil {
+				t.Fatalf("Marshal: %s", err)
+			}
+			if !strings.HasPrefix(string(content), tt.want) {
+				t.Errorf("Marshal(%q, %d) = %q, want it to start with %q", tt.format, tt.indent, content, tt.want)
+			}
+			if tt.format == "json" && tt.indent == 0 && bytes.ContainsAny(content, "\n\t") {
+				t.Errorf("compact JSON has whitespace: %q", content)
+			}
+		})
+	}
+}
+
+func TestSkippedSectionsAreLeftOut(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    func(*Options)
+		present []string
+		absent  []string
+	}{
+		{"nothing skipped", func(*Options) {}, []string{"images", "containers", "volumes"}, nil},
+		{"images skipped", func(o *Options) { o.SkipImages = true }, []string{"containers", "volumes"}, []string{"images"}},
+		{"containers skipped", func(o *Options) { o.SkipContainers = true }, []string{"images", "volumes"}, []string{"containers"}},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"json", "yaml"} {
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				// The host has nothing on it, so a section that was
+				// collected is an empty list rather than missing.
+				opts := testOptions()
+				tt.opts(&opts)
+				o, err := Collect(context.Background(), &fakeDocker{}, opts)
+				if err != nil {
+					t.Fatalf("Collect: %s", err)
+				}
+				content, err := o.Marshal(format, 2)
+				if err != nil {
+					t.Fatalf("Marshal: %s", err)
+				}
+				key := func(name string) string {
+					if format == "json" {
+						return "\n  \"" + name + "\": "
+					}
+					return "\n" + name + ": "
+				}
+				for _, name := range tt.present {
+					if !strings.Contains(string(content), key(name)+"[]") {
+						t.Errorf("%s isn't written as an empty list:\n%s", name, content)
+					}
+				}
+				for _, name := range tt.absent {
+					if strings.Contains(string(content), key(name)) {
+						t.Errorf("%s is written though it was skipped:\n%s", name, content)
+					}
+				}
+				sections := o.Split()
+				for _, name := range tt.absent {
+					if sections[name] != nil {
+						t.Errorf("Split has a %s section though it was skipped", name)
+					}
+				}
+				parsed, err := parseManifest("manifest."+format, content)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, name := range tt.absent {
+					if name == "images" && parsed.Images != nil || name == "containers" && parsed.Containers != nil {
+						t.Errorf("%s parsed back as %v, want nil", name, parsed)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMarshalNDJSON(t *testing.T) {
+	o, err := Collect(context.Background(), &fakeDocker{images: testImages(), containers: testContainers()}, testOptions())
+	if err != nil {
+		t.Fatalf("Collect: %s", err)
+	}
+	o.Labels = map[string]string{"env": "prod"}
+	o.Sources = []*Source{{URI: "tcp://a:2376"}}
+	o.DiskUsage = []*DiskUsage{{Type: "Images", TotalCount: 3}}
+	o.Errors = []string{"listing volumes: failure"}
+	content, err := o.Marshal("ndjson", 0)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	counts := map[string]int{}
+	var metadata map[string]interface{}
+	for n, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line %d: %s", n+1, err)
+		}
+		if record["hostname"] != "test-host" || record["date"] == nil {
+			t.Errorf("line %d has hostname %v and date %v", n+1, record["hostname"], record["date"])
+		}
+		recordType, _ := record["type"].(string)
+		counts[recordType]++
+		if n == 0 {
+			metadata = record
+		}
+	}
+	if metadata["type"] != "metadata" {
+		t.Fatalf("the first record is a %v, want metadata", metadata["type"])
+	}
+	for _, field := range []string{"schema_version", "fester_version", "docker_api_version", "summary", "errors", "timings", "labels", "sources", "system", "disk_usage"} {
+		if metadata[field] == nil {
+			t.Errorf("the metadata record has no %s: %v", field, metadata)
+		}
+	}
+	if labels, _ := metadata["labels"].(map[string]interface{}); labels["env"] != "prod" {
+		t.Errorf("metadata labels = %v", metadata["labels"])
+	}
+	if counts["image"] != 3 || counts["container"] != 2 || counts["metadata"] != 1 {
+		t.Errorf("record counts = %v", counts)
+	}
+}
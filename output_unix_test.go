@@ -0,0 +1,68 @@
+//go:build unix
+
+package fester
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOutputModesIgnoreUmask(t *testing.T) {
+	tests := []struct {
+		name     string
+		umask    int
+		fileMode os.FileMode
+		dirMode  os.FileMode
+	}{
+		{"defaults under a strict umask", 0o077, 0o644, 0o755},
+		{"private modes under no umask", 0, 0o600, 0o700},
+		{"group-writable under the usual umask", 0o022, 0o664, 0o775},
+	}
+	writers := []struct {
+		name  string
+		write func(path string, content []byte) error
+	}{
+		{"WriteOutput", WriteOutput},
+		{"AppendOutput", AppendOutput},
+	}
+	defer func(file, dir os.FileMode) { FileMode, DirMode = file, dir }(FileMode, DirMode)
+	for _, tt := range tests {
+		for _, w := range writers {
+			t.Run(tt.name+"/"+w.name, func(t *testing.T) {
+				root := t.TempDir()
+				info, err := os.Stat(root)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer syscall.Umask(syscall.Umask(tt.umask))
+				FileMode, DirMode = tt.fileMode, tt.dirMode
+				path := filepath.Join(root, "a", "b", "manifest.json")
+				// The second write covers an existing file.
+				for i := 0; i < 2; i++ {
+					if err := w.write(path, []byte("{}\n")); err != nil {
+						t.Fatalf("%s: %s", w.name, err)
+					}
+				}
+				checkPerm(t, path, tt.fileMode)
+				checkPerm(t, filepath.Join(root, "a"), tt.dirMode)
+				checkPerm(t, filepath.Join(root, "a", "b"), tt.dirMode)
+				// An existing directory is left as it was.
+				checkPerm(t, root, info.Mode().Perm())
+			})
+		}
+	}
+}
+
+// checkPerm fails t if the file at path doesn't have the permissions in want.
+func checkPerm(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("%s has mode %#o, want %#o", path, got, want)
+	}
+}
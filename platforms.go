@@ -0,0 +1,89 @@
+package fester
+
+import "context"
+
+// ContainerdSnapshotter is the driver-type docker system info reports for the
+// storage driver when the daemon uses the containerd image store.
+const ContainerdSnapshotter = "io.containerd.snapshotter.v1"
+
+// ImagePlatform is one platform variant of an image in the containerd image
+// store, where a single image can hold several of them. Available is false if
+// the variant is only listed in the image's index, and its content wasn't
+// pulled.
+type ImagePlatform struct {
+	OS           string `json:"OS" yaml:"OS"`
+	Architecture string `json:"Architecture" yaml:"Architecture"`
+	Variant      string `json:"Variant,omitempty" yaml:"Variant,omitempty"`
+	Digest       string `json:"Digest" yaml:"Digest"`
+	Available    bool   `json:"Available" yaml:"Available"`
+	Size         int64  `json:"Size" yaml:"Size"`
+}
+
+// String returns the platform as os/arch or os/arch/variant, such as
+// linux/arm/v7.
+func (p *ImagePlatform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// imageManifests is the subset of an image listed with its manifests by API
+// version 1.47 and later used to build its ImagePlatforms.
+type imageManifests struct {
+	ID        string `json:"Id"`
+	Manifests []struct {
+		Kind       string
+		Available  bool
+		Descriptor struct {
+			Digest   string `json:"digest"`
+			Platform *struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant"`
+			} `json:"platform"`
+		}
+		Size struct {
+			Total int64
+		}
+	}
+}
+
+// ImagePlatforms returns the platform variants of each image in the
+// containerd image store, keyed by image ID. Attestations, such as build
+// provenance, are left out. The docker command doesn't list them, so they're
+// asked for through the API, which needs API version 1.47 or later.
+func (c *Client) ImagePlatforms(ctx context.Context) (map[string][]*ImagePlatform, error) {
+	var listed []*imageManifests
+	if err := c.apiGet(ctx, "/v1.47/images/json?all=1&manifests=1", &listed); err != nil {
+		return nil, err
+	}
+	platforms := make(map[string][]*ImagePlatform)
+	for _, i := range listed {
+		for _, m := range i.Manifests {
+			if m.Kind != "image" || m.Descriptor.Platform == nil {
+				continue
+			}
+			platforms[i.ID] = append(platforms[i.ID], &ImagePlatform{
+				OS:           m.Descriptor.Platform.OS,
+				Architecture: m.Descriptor.Platform.Architecture,
+				Variant:      m.Descriptor.Platform.Variant,
+				Digest:       m.Descriptor.Digest,
+				Available:    m.Available,
+				Size:         m.Size.Total,
+			})
+		}
+	}
+	return platforms, nil
+}
+
+// AddPlatforms sets the Platforms of each of images that has any in
+// platforms, as returned by ImagePlatforms.
+func AddPlatforms(images []*Image, platforms map[string][]*ImagePlatform) {
+	for _, i := range images {
+		if p, ok := platforms[i.ID]; ok {
+			i.Platforms = p
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package fester
+
+import "context"
+
+// Plugin contains the info reported by docker about a plugin installed on the
+// daemon, such as a volume or network driver.
+type Plugin struct {
+	ID              string `json:"Id" yaml:"Id"`
+	Name            string `json:"Name" yaml:"Name"`
+	Enabled         bool   `json:"Enabled" yaml:"Enabled"`
+	PluginReference string `json:"PluginReference" yaml:"PluginReference"`
+	SourceURI       string `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// ListPlugins returns the plugins installed on the Docker daemon.
+func (c *Client) ListPlugins(ctx context.Context) ([]*Plugin, error) {
+	plugins := []*Plugin{}
+	if err := c.inspect(ctx, "plugin", &plugins, "--no-trunc"); err != nil {
+		return nil, err
+	}
+	return plugins, nil
+}
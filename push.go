@@ -0,0 +1,190 @@
+package fester
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// MaxFrameSize is the largest manifest ReadFrame accepts.
+const MaxFrameSize = 256 << 20
+
+// WriteFrame writes payload to w as one frame of the push protocol: its
+// length as a 4-byte big-endian unsigned integer, followed by the payload
+// itself.
+func WriteFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadFrame reads one frame written by WriteFrame from r and returns its
+// payload. It returns io.EOF if r ends cleanly between frames.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n > MaxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes is bigger than the limit of %d", n, MaxFrameSize)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Pusher pushes manifests to a collector over one long-lived TCP connection,
+// one frame per manifest. The connection is made when the first manifest is
+// pushed, and made again, backing off between attempts, whenever it fails. A
+// push gives up after MaxAttempts attempts or Timeout, whichever comes first,
+// so an unreachable collector can't hold up the manifests after it.
+type Pusher struct {
+	// Addr is the host:port of the collector.
+	Addr string
+	// TLSConfig, if set, connects with TLS rather than over plain TCP.
+	TLSConfig *tls.Config
+	// MaxBackoff caps the wait between connection attempts, which starts
+	// at a second and doubles after each failure.
+	MaxBackoff time.Duration
+	// MaxAttempts is how many times a push tries to connect and write the
+	// manifest before giving up. Zero means no limit.
+	MaxAttempts int
+	// Timeout limits how long a push may take, reconnecting included. Zero
+	// means no limit.
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+}
+
+// NewPusher returns a *Pusher for the collector at addr, connecting with TLS
+// if tlsConfig isn't nil. A push makes up to 5 attempts within a minute.
+func NewPusher(addr string, tlsConfig *tls.Config) *Pusher {
+	return &Pusher{Addr: addr, TLSConfig: tlsConfig, MaxBackoff: time.Minute, MaxAttempts: 5, Timeout: time.Minute}
+}
+
+// Push sends content to the collector as one frame, connecting to it again
+// until the frame is written, p.MaxAttempts attempts have failed, or the
+// p.Timeout or ctx is done.
+func (p *Pusher) Push(ctx context.Context, content []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	for attempt := 1; ; attempt++ {
+		last := p.MaxAttempts > 0 && attempt >= p.MaxAttempts
+		if p.conn != nil && !alive(p.conn) {
+			slog.Warn("the collector closed the connection, reconnecting", "addr", p.Addr)
+			p.conn.Close()
+			p.conn = nil
+		}
+		if p.conn == nil {
+			conn, err := p.dial(ctx)
+			if err != nil {
+				if ctx.Err() != nil || last {
+					return fmt.Errorf("connecting to %s: %s", p.Addr, err)
+				}
+				wait := p.nextBackoff()
+				slog.Warn("connecting to the collector failed, retrying", "addr", p.Addr, "in", wait, "error", err)
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("connecting to %s: %s", p.Addr, err)
+				case <-time.After(wait):
+				}
+				continue
+			}
+			slog.Debug("connected to the collector", "addr", p.Addr)
+			p.conn, p.backoff = conn, 0
+		}
+		err := p.write(ctx, content)
+		if err == nil {
+			return nil
+		}
+		p.conn.Close()
+		p.conn = nil
+		if ctx.Err() != nil || last {
+			return fmt.Errorf("pushing to %s: %s", p.Addr, err)
+		}
+		slog.Warn("pushing to the collector failed, reconnecting", "addr", p.Addr, "error", err)
+	}
+}
+
+// write writes content to the connection as one frame, giving up when ctx is
+// done.
+func (p *Pusher) write(ctx context.Context, content []byte) error {
+	conn := p.conn
+	conn.SetWriteDeadline(time.Time{})
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetWriteDeadline(time.Now())
+	})
+	defer stop()
+	return WriteFrame(conn, content)
+}
+
+// alive returns false if the collector has closed conn. The collector never
+// sends anything, so a read that doesn't time out means the connection is
+// gone. Without this check a frame written to a connection the collector has
+// just closed would seem to succeed, and be lost. The deadline is just after
+// now rather than now, since a read past its deadline isn't even tried.
+func alive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// dial connects to the collector.
+func (p *Pusher) dial(ctx context.Context) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if p.TLSConfig != nil {
+		return (&tls.Dialer{NetDialer: d, Config: p.TLSConfig}).DialContext(ctx, "tcp", p.Addr)
+	}
+	return d.DialContext(ctx, "tcp", p.Addr)
+}
+
+// nextBackoff returns how long to wait before the next connection attempt,
+// doubling the wait each time up to p.MaxBackoff.
+func (p *Pusher) nextBackoff() time.Duration {
+	if p.backoff == 0 {
+		p.backoff = time.Second
+	} else {
+		p.backoff *= 2
+	}
+	if p.MaxBackoff > 0 && p.backoff > p.MaxBackoff {
+		p.backoff = p.MaxBackoff
+	}
+	return p.backoff
+}
+
+// Close closes the connection to the collector, if there is one.
+func (p *Pusher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
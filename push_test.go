@@ -0,0 +1,88 @@
+package fester
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// closedAddr returns the address of a port nothing is listening on.
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestPush(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan []byte, 2)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			frame, err := ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			received <- frame
+		}
+	}()
+	p := NewPusher(l.Addr().String(), nil)
+	defer p.Close()
+	for _, m := range []string{`{"hostname":"a"}`, `{"hostname":"b"}`} {
+		if err := p.Push(context.Background(), []byte(m)); err != nil {
+			t.Fatalf("Push: %s", err)
+		}
+		select {
+		case got := <-received:
+			if !bytes.Equal(got, []byte(m)) {
+				t.Errorf("received %q, want %q", got, m)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the collector didn't receive the manifest")
+		}
+	}
+}
+
+func TestPushGivesUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		timeout     time.Duration
+	}{
+		{"after MaxAttempts", 3, 0},
+		{"after Timeout", 0, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPusher(closedAddr(t), nil)
+			p.MaxBackoff = 10 * time.Millisecond
+			p.MaxAttempts, p.Timeout = tt.maxAttempts, tt.timeout
+			done := make(chan error, 1)
+			go func() {
+				done <- p.Push(context.Background(), []byte("{}"))
+			}()
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Fatal("Push succeeded, want an error")
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Push didn't give up")
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package fester
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Reconnecting is a Docker that creates the Docker it passes calls on to with
+// New the first time it's needed, and reuses it for every call after that, so
+// that the collections of -interval, -watch, and -listen share one client. If
+// a call fails because the daemon couldn't be reached, the client is closed,
+// if it's an io.Closer, and dropped, and a new one is created for the next
+// call in case the old one's connection has gone bad.
+type Reconnecting struct {
+	// New creates the client.
+	New func() Docker
+
+	mu  sync.Mutex
+	cli Docker
+}
+
+// NewReconnecting returns a *Reconnecting that creates its clients with
+// newDocker.
+func NewReconnecting(newDocker func() Docker) *Reconnecting {
+	return &Reconnecting{New: newDocker}
+}
+
+// client returns the current client, creating it if there isn't one.
+func (r *Reconnecting) client() Docker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cli == nil {
+		r.cli = r.New()
+	}
+	return r.cli
+}
+
+// check drops cli, the client a call was made with, if err shows the daemon
+// couldn't be reached, and returns err.
+func (r *Reconnecting) check(cli Docker, err error) error {
+	if err == nil || !IsTransient(err) {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cli == cli {
+		slog.Debug("the Docker client failed to connect, creating a new one for the next call", "error", err)
+		closeDocker(r.cli)
+		r.cli = nil
+	}
+	return err
+}
+
+// Close closes the current client, if there is one and it's an io.Closer.
+func (r *Reconnecting) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := closeDocker(r.cli)
+	r.cli = nil
+	return err
+}
+
+// closeDocker closes cli if it's an io.Closer.
+func closeDocker(cli Docker) error {
+	if c, ok := cli.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (r *Reconnecting) APIVersion(ctx context.Context) (string, error) {
+	cli := r.client()
+	v, err := cli.APIVersion(ctx)
+	return v, r.check(cli, err)
+}
+
+func (r *Reconnecting) Pull(ctx context.Context, image string) error {
+	cli := r.client()
+	return r.check(cli, cli.Pull(ctx, image))
+}
+
+func (r *Reconnecting) Version(ctx context.Context, image string) (*VersionInfo, error) {
+	cli := r.client()
+	v, err := cli.Version(ctx, image)
+	return v, r.check(cli, err)
+}
+
+func (r *Reconnecting) ListImages(ctx context.Context, filters Filters) ([]*Image, error) {
+	cli := r.client()
+	images, err := cli.ListImages(ctx, filters)
+	return images, r.check(cli, err)
+}
+
+func (r *Reconnecting) InspectImage(ctx context.Context, ref string) (*Image, error) {
+	cli := r.client()
+	image, err := cli.InspectImage(ctx, ref)
+	return image, r.check(cli, err)
+}
+
+func (r *Reconnecting) ImageHistory(ctx context.Context, id string) ([]*HistoryItem, error) {
+	cli := r.client()
+	history, err := cli.ImageHistory(ctx, id)
+	return history, r.check(cli, err)
+}
+
+func (r *Reconnecting) ImagePlatforms(ctx context.Context) (map[string][]*ImagePlatform, error) {
+	cli := r.client()
+	platforms, err := cli.ImagePlatforms(ctx)
+	return platforms, r.check(cli, err)
+}
+
+func (r *Reconnecting) ListContainers(ctx context.Context, filters Filters) ([]*Container, error) {
+	cli := r.client()
+	containers, err := cli.ListContainers(ctx, filters)
+	return containers, r.check(cli, err)
+}
+
+func (r *Reconnecting) InspectContainer(ctx context.Context, id string) (*ContainerDetails, error) {
+	cli := r.client()
+	d, err := cli.InspectContainer(ctx, id)
+	return d, r.check(cli, err)
+}
+
+func (r *Reconnecting) ContainerStats(ctx context.Context, id string) (*ContainerStats, error) {
+	cli := r.client()
+	stats, err := cli.ContainerStats(ctx, id)
+	return stats, r.check(cli, err)
+}
+
+func (r *Reconnecting) ContainerLogs(ctx context.Context, id string, tail int) (string, error) {
+	cli := r.client()
+	logs, err := cli.ContainerLogs(ctx, id, tail)
+	return logs, r.check(cli, err)
+}
+
+func (r *Reconnecting) ListVolumes(ctx context.Context) ([]*Volume, error) {
+	cli := r.client()
+	volumes, err := cli.ListVolumes(ctx)
+	return volumes, r.check(cli, err)
+}
+
+func (r *Reconnecting) ListNetworks(ctx context.Context) ([]*Network, error) {
+	cli := r.client()
+	networks, err := cli.ListNetworks(ctx)
+	return networks, r.check(cli, err)
+}
+
+func (r *Reconnecting) ListPlugins(ctx context.Context) ([]*Plugin, error) {
+	cli := r.client()
+	plugins, err := cli.ListPlugins(ctx)
+	return plugins, r.check(cli, err)
+}
+
+func (r *Reconnecting) SystemInfo(ctx context.Context) (*System, error) {
+	cli := r.client()
+	system, err := cli.SystemInfo(ctx)
+	return system, r.check(cli, err)
+}
+
+func (r *Reconnecting) DiskUsage(ctx context.Context) ([]*DiskUsage, error) {
+	cli := r.client()
+	usage, err := cli.DiskUsage(ctx)
+	return usage, r.check(cli, err)
+}
+
+func (r *Reconnecting) Events(ctx context.Context, filters Filters, handle func(*Event)) error {
+	cli := r.client()
+	return r.check(cli, cli.Events(ctx, filters, handle))
+}
+
+func (r *Reconnecting) SwarmManager(ctx context.Context) (bool, error) {
+	cli := r.client()
+	manager, err := cli.SwarmManager(ctx)
+	return manager, r.check(cli, err)
+}
+
+func (r *Reconnecting) ListServices(ctx context.Context) ([]*Service, error) {
+	cli := r.client()
+	services, err := cli.ListServices(ctx)
+	return services, r.check(cli, err)
+}
+
+func (r *Reconnecting) ListTasks(ctx context.Context, services []*Service) ([]*Task, error) {
+	cli := r.client()
+	tasks, err := cli.ListTasks(ctx, services)
+	return tasks, r.check(cli, err)
+}
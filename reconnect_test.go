@@ -0,0 +1,78 @@
+package fester
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// closingDocker is a fakeDocker that counts how often it's closed.
+type closingDocker struct {
+	*fakeDocker
+	closed int
+}
+
+func (c *closingDocker) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestReconnectingReusesClient(t *testing.T) {
+	fake := &fakeDocker{images: testImages(), containers: testContainers()}
+	created := 0
+	cli := NewReconnecting(func() Docker {
+		created++
+		return fake
+	})
+	for cycle := 0; cycle < 50; cycle++ {
+		if _, err := Collect(context.Background(), cli, testOptions()); err != nil {
+			t.Fatalf("cycle %d: Collect: %s", cycle, err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("created %d clients over 50 collections, want 1", created)
+	}
+}
+
+func TestReconnectingRecreatesAfterConnectionFailure(t *testing.T) {
+	unreachable := errors.New("Cannot connect to the Docker daemon at unix:///var/run/docker.sock")
+	var clients []*closingDocker
+	cli := NewReconnecting(func() Docker {
+		c := &closingDocker{fakeDocker: &fakeDocker{}}
+		clients = append(clients, c)
+		return c
+	})
+	ctx := context.Background()
+	if _, err := Collect(ctx, cli, testOptions()); err != nil {
+		t.Fatalf("Collect: %s", err)
+	}
+	// A call that fails for some other reason keeps the client.
+	clients[0].errs = map[string]error{"APIVersion": errors.New("Error response from daemon: bad request")}
+	if _, err := Collect(ctx, cli, testOptions()); err == nil {
+		t.Fatal("Collect succeeded, want an error")
+	}
+	if len(clients) != 1 {
+		t.Fatalf("created %d clients after a non-connection error, want 1", len(clients))
+	}
+	clients[0].errs = map[string]error{"APIVersion": unreachable}
+	if _, err := Collect(ctx, cli, testOptions()); err == nil {
+		t.Fatal("Collect succeeded, want an error")
+	}
+	if clients[0].closed != 1 {
+		t.Errorf("the failed client was closed %d times, want 1", clients[0].closed)
+	}
+	for cycle := 0; cycle < 10; cycle++ {
+		if _, err := Collect(ctx, cli, testOptions()); err != nil {
+			t.Fatalf("cycle %d: Collect: %s", cycle, err)
+		}
+	}
+	if len(clients) != 2 {
+		t.Errorf("created %d clients, want 2: the first, and one after it failed to connect", len(clients))
+	}
+	if err := cli.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+	if clients[1].closed != 1 {
+		t.Errorf("the last client was closed %d times, want 1", clients[1].closed)
+	}
+}
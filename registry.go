@@ -0,0 +1,82 @@
+package fester
+
+import "strings"
+
+// DefaultRegistry is the registry docker pulls from when an image name doesn't
+// name one.
+const DefaultRegistry = "docker.io"
+
+// ParseReference splits an image reference, such as nginx:latest,
+// quay.io/org/app@sha256:..., or localhost:5000/app, into the registry host and
+// the repository, with the tag or digest dropped. As docker does, it treats
+// the first component of the name as a registry host only if it contains a .
+// or a :, or is localhost, and otherwise uses DefaultRegistry. Official images
+// on DefaultRegistry, which have no / in their name, get the library/ prefix.
+func ParseReference(ref string) (string, string) {
+	if n := strings.Index(ref, "@"); n >= 0 {
+		ref = ref[:n]
+	}
+	registry, repo := DefaultRegistry, ref
+	if n := strings.Index(ref, "/"); n >= 0 {
+		first := ref[:n]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			registry, repo = first, ref[n+1:]
+		}
+	}
+	// A : after the last / separates the tag; one before it is a port.
+	if n := strings.LastIndex(repo, ":"); n > strings.LastIndex(repo, "/") {
+		repo = repo[:n]
+	}
+	if registry == "index.docker.io" {
+		registry = DefaultRegistry
+	}
+	if registry == DefaultRegistry && !strings.Contains(repo, "/") {
+		repo = "library/" + repo
+	}
+	return registry, repo
+}
+
+// imageRegistries returns the registries the image has tags or digests in,
+// without duplicates.
+func imageRegistries(i *Image) []string {
+	refs := i.RepoTags
+	if len(refs) == 0 {
+		refs = i.RepoDigests
+	}
+	seen := make(map[string]bool)
+	var registries []string
+	for _, ref := range refs {
+		if ref == "<none>:<none>" || ref == "<none>@<none>" {
+			continue
+		}
+		registry, _ := ParseReference(ref)
+		if !seen[registry] {
+			seen[registry] = true
+			registries = append(registries, registry)
+		}
+	}
+	return registries
+}
+
+// FilterRegistries returns the images with a tag, or a digest if they are
+// untagged, in one of registries. If registries is empty every image is
+// returned.
+func FilterRegistries(images []*Image, registries []string) []*Image {
+	if len(registries) == 0 {
+		return images
+	}
+	want := make(map[string]bool)
+	for _, r := range registries {
+		want[r] = true
+	}
+	kept := []*Image{}
+	for _, i := range images {
+		for _, r := range imageRegistries(i) {
+			if want[r] {
+				kept = append(kept, i)
+				break
+			}
+		}
+	}
+	return kept
+}
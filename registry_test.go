@@ -0,0 +1,33 @@
+package fester
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref, registry, repo string
+	}{
+		{"nginx", "docker.io", "library/nginx"},
+		{"nginx:latest", "docker.io", "library/nginx"},
+		{"nginx@sha256:abc", "docker.io", "library/nginx"},
+		{"nginx:1.25@sha256:abc", "docker.io", "library/nginx"},
+		{"grafana/grafana:10.0", "docker.io", "grafana/grafana"},
+		{"docker.io/nginx", "docker.io", "library/nginx"},
+		{"docker.io/grafana/grafana", "docker.io", "grafana/grafana"},
+		{"index.docker.io/nginx:latest", "docker.io", "library/nginx"},
+		{"quay.io/org/app@sha256:abc", "quay.io", "org/app"},
+		{"ghcr.io/org/team/app:v1", "ghcr.io", "org/team/app"},
+		{"localhost/app", "localhost", "app"},
+		{"localhost:5000/app", "localhost:5000", "app"},
+		{"localhost:5000/app:1.0", "localhost:5000", "app"},
+		{"registry.example.com:5000/team/app:1.0", "registry.example.com:5000", "team/app"},
+		{"myorg/app", "docker.io", "myorg/app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			registry, repo := ParseReference(tt.ref)
+			if registry != tt.registry || repo != tt.repo {
+				t.Errorf("ParseReference(%q) = %q, %q, want %q, %q", tt.ref, registry, repo, tt.registry, tt.repo)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package fester
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ExpandKey replaces the {hostname}, {date}, and {unix} placeholders in key
+// with the hostname, date, and Unix timestamp of the manifest.
+func ExpandKey(key string, o *OutputMap) string {
+	return strings.NewReplacer(
+		"{hostname}", o.Hostname,
+		"{date}", o.Date.String(),
+		"{unix}", strconv.FormatInt(o.Date.Unix(), 10),
+	).Replace(key)
+}
+
+// UploadS3 uploads content to key in bucket with the given Content-Type, and
+// with a Content-Encoding of gzip if gzipped is set. Credentials and the region
+// come from the standard AWS environment variables and config files. If
+// endpoint is set it is used instead of AWS, with path-style addressing, for
+// S3-compatible storage such as MinIO.
+func UploadS3(ctx context.Context, endpoint, bucket, key string, content []byte, contentType string, gzipped bool) error {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String(contentType),
+	}
+	if gzipped {
+		input.ContentEncoding = aws.String("gzip")
+	}
+	_, err = client.PutObject(ctx, input)
+	return err
+}
@@ -0,0 +1,40 @@
+package fester
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Schema is the JSON Schema that manifests, encoded as JSON, conform to. Its
+// schema_version must be kept in sync with SchemaVersion.
+//
+//go:embed schema.json
+var Schema []byte
+
+// schemaURL is the $id of Schema.
+const schemaURL = "https://github.com/johnworth/fester/schema/v1.json"
+
+// ValidateManifest checks that o, encoded as JSON, conforms to Schema.
+func ValidateManifest(o *OutputMap) error {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaURL, bytes.NewReader(Schema)); err != nil {
+		return fmt.Errorf("loading schema: %s", err)
+	}
+	schema, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return fmt.Errorf("compiling schema: %s", err)
+	}
+	content, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	if err = json.Unmarshal(content, &v); err != nil {
+		return err
+	}
+	return schema.Validate(v)
+}
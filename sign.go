@@ -0,0 +1,70 @@
+package fester
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// ErrBadSignature is returned by VerifySignature when the signature doesn't
+// match the content or wasn't made by the given key.
+var ErrBadSignature = errors.New("the signature doesn't match")
+
+// Signer makes detached OpenPGP signatures with a private key.
+type Signer struct {
+	entity *openpgp.Entity
+}
+
+// NewSigner reads the ASCII-armored OpenPGP private key in keyFile. If the key
+// is encrypted it is decrypted with passphrase.
+func NewSigner(keyFile, passphrase string) (*Signer, error) {
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", keyFile, err)
+	}
+	for _, e := range entities {
+		if e.PrivateKey == nil {
+			continue
+		}
+		if e.PrivateKey.Encrypted {
+			if passphrase == "" {
+				return nil, fmt.Errorf("the key in %s is encrypted and no passphrase was given", keyFile)
+			}
+			if err = e.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return nil, fmt.Errorf("decrypting the key in %s: %s", keyFile, err)
+			}
+		}
+		return &Signer{entity: e}, nil
+	}
+	return nil, fmt.Errorf("no private key found in %s", keyFile)
+}
+
+// Sign returns an ASCII-armored detached signature of content.
+func (s *Signer) Sign(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, s.entity, bytes.NewReader(content), nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// VerifySignature checks that sig, an ASCII-armored detached signature, was
+// made of content by one of the keys in the ASCII-armored public keyring. It
+// returns ErrBadSignature if it wasn't.
+func VerifySignature(content, sig, keyring []byte) error {
+	keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyring))
+	if err != nil {
+		return fmt.Errorf("parsing public key: %s", err)
+	}
+	if _, err = openpgp.CheckArmoredDetachedSignature(keys, bytes.NewReader(content), bytes.NewReader(sig)); err != nil {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package fester
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestSection is the name of the section Split puts the manifest's
+// metadata and summary in.
+const ManifestSection = "manifest"
+
+// Split splits the manifest into sections, keyed by name: images,
+// containers, volumes, networks, and so on, each holding one of the lists,
+// along with ManifestSection, holding the top-level fields and summary. Each
+// section is also stamped with the hostname and date of the manifest.
+// Sections with nothing in them are left out, except for images, containers,
+// volumes, and networks, which are only left out if they weren't collected,
+// and those that ran out of time, which hold a "timeout": true marker.
+func (o *OutputMap) Split() map[string]map[string]interface{} {
+	sections := map[string]map[string]interface{}{
+		ManifestSection: {
+			"schema_version":     o.SchemaVersion,
+			"fester_version":     o.FesterVersion,
+			"docker_api_version": o.DockerAPIVersion,
+			"summary":            o.Summary,
+		},
+		"networks": {"networks": o.Networks},
+	}
+	if o.Volumes != nil {
+		sections["volumes"] = map[string]interface{}{"volumes": o.Volumes}
+	}
+	if o.Images != nil {
+		sections["images"] = map[string]interface{}{"images": o.Images}
+	}
+	if o.Containers != nil {
+		sections["containers"] = map[string]interface{}{"containers": o.Containers}
+	}
+	if len(o.Labels) > 0 {
+		sections[ManifestSection]["labels"] = o.Labels
+	}
+	if len(o.Sources) > 0 {
+		sections[ManifestSection]["sources"] = o.Sources
+	}
+	if len(o.TimedOut) > 0 {
+		sections[ManifestSection]["timed_out"] = o.TimedOut
+	}
+	if len(o.Errors) > 0 {
+		sections[ManifestSection]["errors"] = o.Errors
+	}
+	if len(o.Timings) > 0 {
+		sections[ManifestSection]["timings"] = o.Timings
+	}
+	if o.System != nil {
+		sections[ManifestSection]["system"] = o.System
+	}
+	if len(o.Projects) > 0 && sections["containers"] != nil {
+		sections["containers"]["projects"] = o.Projects
+	}
+	add := func(name string, n int, v interface{}) {
+		if n > 0 {
+			sections[name] = map[string]interface{}{name: v}
+		}
+	}
+	add("docker_images", len(o.DockerImages), o.DockerImages)
+	add("disk_usage", len(o.DiskUsage), o.DiskUsage)
+	add("files", len(o.Files), o.Files)
+	add("plugins", len(o.Plugins), o.Plugins)
+	add("services", len(o.Services), o.Services)
+	add("tasks", len(o.Tasks), o.Tasks)
+	for name := range o.timeoutFields() {
+		if name == "system" {
+			sections[ManifestSection][name] = timeoutMarker
+		} else {
+			sections[name] = map[string]interface{}{name: timeoutMarker}
+		}
+	}
+	for _, section := range sections {
+		section["hostname"] = o.Hostname
+		section["date"] = o.Date
+	}
+	return sections
+}
+
+// WriteSplit writes each of the sections Split returns to its own file in
+// dir, named after the section with the format as its extension, such as
+// images.json. Only json and yaml are supported.
+func (o *OutputMap) WriteSplit(dir, format string, indent int) error {
+	if format != "json" && format != "yaml" {
+		return fmt.Errorf("can't split a manifest into %s files, only json or yaml", format)
+	}
+	sections := o.Split()
+	var names []string
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content, err := marshal(sections[name], format, indent)
+		if err != nil {
+			return fmt.Errorf("marshalling %s: %s", name, err)
+		}
+		if err = WriteOutput(filepath.Join(dir, name+"."+format), content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package fester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContainerStats is a sample of the resources a container was using at one
+// moment. They aren't averages, so they only give a rough idea of how busy a
+// container is.
+type ContainerStats struct {
+	CPUPercent       float64 `json:"CPUPercent" yaml:"CPUPercent"`
+	MemoryUsageBytes int64   `json:"MemoryUsageBytes" yaml:"MemoryUsageBytes"`
+	MemoryLimitBytes int64   `json:"MemoryLimitBytes" yaml:"MemoryLimitBytes"`
+	MemoryPercent    float64 `json:"MemoryPercent" yaml:"MemoryPercent"`
+}
+
+// statsLine is a line of docker stats --format {{json .}} output.
+type statsLine struct {
+	CPUPerc  string
+	MemUsage string
+	MemPerc  string
+}
+
+// ContainerStats returns a sample of the resources the container with the
+// given ID is using. docker only reports them in human-readable form, so they
+// are only as precise as docker prints them.
+func (c *Client) ContainerStats(ctx context.Context, id string) (*ContainerStats, error) {
+	stdout, stderr, err := c.Output(ctx, "container", "stats", "--no-stream", "--no-trunc", "--format", "{{json .}}", id)
+	if err != nil {
+		return nil, err
+	}
+	logWarnings(stderr)
+	var l statsLine
+	if err = json.Unmarshal(stdout, &l); err != nil {
+		return nil, err
+	}
+	s := &ContainerStats{}
+	if s.CPUPercent, err = parsePercent(l.CPUPerc); err != nil {
+		return nil, err
+	}
+	if s.MemoryPercent, err = parsePercent(l.MemPerc); err != nil {
+		return nil, err
+	}
+	usage := strings.SplitN(l.MemUsage, "/", 2)
+	if len(usage) != 2 {
+		return nil, fmt.Errorf("parsing memory usage %q", l.MemUsage)
+	}
+	if s.MemoryUsageBytes, err = ParseSize(usage[0]); err != nil {
+		return nil, err
+	}
+	if s.MemoryLimitBytes, err = ParseSize(usage[1]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parsePercent parses a percentage printed by docker, such as "0.52%".
+func parsePercent(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing percentage %q: %s", s, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,100 @@
+package fester
+
+import "strings"
+
+// Summary contains counts that give an at-a-glance view of the manifest.
+type Summary struct {
+	ImageCount            int `json:"image_count" yaml:"image_count"`
+	ContainerCount        int `json:"container_count" yaml:"container_count"`
+	RunningContainerCount int `json:"running_container_count" yaml:"running_container_count"`
+	// TotalImageSizeBytes is the naive sum of the reported size of each
+	// image. Layers shared between images are counted once per image.
+	TotalImageSizeBytes int64 `json:"total_image_size_bytes" yaml:"total_image_size_bytes"`
+	// UnusedImageCount and UnusedImageSizeBytes count the images that aren't
+	// InUse, and their naive total size.
+	UnusedImageCount     int   `json:"unused_image_count" yaml:"unused_image_count"`
+	UnusedImageSizeBytes int64 `json:"unused_image_size_bytes" yaml:"unused_image_size_bytes"`
+	// ImagesByRegistry counts the images with a tag in each registry. An
+	// image tagged in several registries is counted in each of them, and
+	// images without tags or digests aren't counted.
+	ImagesByRegistry map[string]int `json:"images_by_registry,omitempty" yaml:"images_by_registry,omitempty"`
+	// Builds gives the source revision and build date of each image that
+	// carries the OCI labels for them.
+	Builds []*ImageBuild `json:"builds,omitempty" yaml:"builds,omitempty"`
+	// TotalReclaimableBytes is the space that could be reclaimed across all
+	// of the disk usage types. It's only set when disk usage is collected.
+	TotalReclaimableBytes int64 `json:"total_reclaimable_bytes,omitempty" yaml:"total_reclaimable_bytes,omitempty"`
+	// ExcludedImageCount and ExcludedContainerCount are the number of images
+	// and containers left out by the exclude patterns.
+	ExcludedImageCount     int `json:"excluded_image_count,omitempty" yaml:"excluded_image_count,omitempty"`
+	ExcludedContainerCount int `json:"excluded_container_count,omitempty" yaml:"excluded_container_count,omitempty"`
+	// The service counts are only set when Swarm services are collected.
+	// DesiredReplicaCount counts the replicas of replicated services, and
+	// RunningTaskCount the running tasks of every service.
+	ServiceCount        int    `json:"service_count,omitempty" yaml:"service_count,omitempty"`
+	DesiredReplicaCount uint64 `json:"desired_replica_count,omitempty" yaml:"desired_replica_count,omitempty"`
+	RunningTaskCount    int    `json:"running_task_count,omitempty" yaml:"running_task_count,omitempty"`
+	// The restart counts are only set when containers are inspected.
+	// MaxRestartCount is the most times any container has been restarted,
+	// and RestartedContainers names the containers restarted more often than
+	// the restart threshold.
+	MaxRestartCount     int      `json:"max_restart_count,omitempty" yaml:"max_restart_count,omitempty"`
+	RestartedContainers []string `json:"restarted_containers,omitempty" yaml:"restarted_containers,omitempty"`
+}
+
+// NewSummary returns a *Summary of the images and containers. If containers
+// is nil they weren't collected, so which images are in use isn't known and
+// the unused image counts are left at zero.
+func NewSummary(images []*Image, containers []*Container) *Summary {
+	s := &Summary{
+		ImageCount:     len(images),
+		ContainerCount: len(containers),
+		Builds:         ImageBuilds(images),
+	}
+	for _, i := range images {
+		s.TotalImageSizeBytes += i.Size
+		if !i.InUse && containers != nil {
+			s.UnusedImageCount++
+			s.UnusedImageSizeBytes += i.Size
+		}
+		for _, r := range imageRegistries(i) {
+			if s.ImagesByRegistry == nil {
+				s.ImagesByRegistry = make(map[string]int)
+			}
+			s.ImagesByRegistry[r]++
+		}
+	}
+	for _, c := range containers {
+		if c.State == "running" {
+			s.RunningContainerCount++
+		}
+	}
+	return s
+}
+
+// AddRestarts adds the restart counts of the containers, listing those
+// restarted more than threshold times by name.
+func (s *Summary) AddRestarts(containers []*Container, threshold int) {
+	for _, c := range containers {
+		if c.RestartCount > s.MaxRestartCount {
+			s.MaxRestartCount = c.RestartCount
+		}
+		if c.RestartCount > threshold {
+			name := strings.TrimPrefix(firstOf(c.Names), "/")
+			s.RestartedContainers = append(s.RestartedContainers, name)
+		}
+	}
+}
+
+// AddServices adds the counts of the Swarm services and their tasks.
+func (s *Summary) AddServices(services []*Service, tasks []*Task) {
+	s.ServiceCount += len(services)
+	for _, svc := range services {
+		s.DesiredReplicaCount += svc.Replicas
+	}
+	for _, t := range tasks {
+		if t.State == "running" {
+			s.RunningTaskCount++
+		}
+	}
+}
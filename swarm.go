@@ -0,0 +1,159 @@
+package fester
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Service contains the info reported by docker about a Swarm service.
+type Service struct {
+	ID      string            `json:"ID" yaml:"ID"`
+	Name    string            `json:"Name" yaml:"Name"`
+	Image   string            `json:"Image" yaml:"Image"`
+	Mode    string            `json:"Mode" yaml:"Mode"`
+	Created int64             `json:"Created" yaml:"Created"`
+	Labels  map[string]string `json:"Labels" yaml:"Labels"`
+	// Replicas is the number of tasks the service should have running. It is
+	// only set for replicated services.
+	Replicas  uint64 `json:"Replicas" yaml:"Replicas"`
+	SourceURI string `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// Task contains the info reported by docker about a task of a Swarm service.
+type Task struct {
+	ID           string `json:"ID" yaml:"ID"`
+	ServiceID    string `json:"ServiceID" yaml:"ServiceID"`
+	NodeID       string `json:"NodeID" yaml:"NodeID"`
+	Slot         int    `json:"Slot,omitempty" yaml:"Slot,omitempty"`
+	Image        string `json:"Image" yaml:"Image"`
+	Created      int64  `json:"Created" yaml:"Created"`
+	DesiredState string `json:"DesiredState" yaml:"DesiredState"`
+	State        string `json:"State" yaml:"State"`
+	Error        string `json:"Error,omitempty" yaml:"Error,omitempty"`
+	SourceURI    string `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// serviceInspect is the subset of docker service inspect output used to build
+// a Service.
+type serviceInspect struct {
+	ID        string
+	CreatedAt time.Time
+	Spec      struct {
+		Name         string
+		Labels       map[string]string
+		TaskTemplate struct {
+			ContainerSpec struct {
+				Image string
+			}
+		}
+		Mode struct {
+			Replicated *struct {
+				Replicas uint64
+			}
+			Global *struct{}
+		}
+	}
+}
+
+// taskInspect is the subset of docker inspect output for a task used to build
+// a Task.
+type taskInspect struct {
+	ID        string
+	ServiceID string
+	NodeID    string
+	Slot      int
+	CreatedAt time.Time
+	Spec      struct {
+		ContainerSpec struct {
+			Image string
+		}
+	}
+	DesiredState string
+	Status       struct {
+		State string
+		Err   string
+	}
+}
+
+// SwarmManager returns true if the daemon is a Swarm manager, and so can list
+// services and tasks.
+func (c *Client) SwarmManager(ctx context.Context) (bool, error) {
+	stdout, stderr, err := c.Output(ctx, "system", "info", "--format", "{{.Swarm.ControlAvailable}}")
+	if err != nil {
+		return false, err
+	}
+	logWarnings(stderr)
+	return strings.TrimSpace(string(stdout)) == "true", nil
+}
+
+// ListServices returns the services in the Swarm.
+func (c *Client) ListServices(ctx context.Context) ([]*Service, error) {
+	var inspected []*serviceInspect
+	if err := c.inspect(ctx, "service", &inspected); err != nil {
+		return nil, err
+	}
+	services := []*Service{}
+	for _, i := range inspected {
+		s := &Service{
+			ID:      i.ID,
+			Name:    i.Spec.Name,
+			Image:   i.Spec.TaskTemplate.ContainerSpec.Image,
+			Created: i.CreatedAt.Unix(),
+			Labels:  i.Spec.Labels,
+		}
+		switch {
+		case i.Spec.Mode.Replicated != nil:
+			s.Mode = "replicated"
+			s.Replicas = i.Spec.Mode.Replicated.Replicas
+		case i.Spec.Mode.Global != nil:
+			s.Mode = "global"
+		}
+		services = append(services, s)
+	}
+	return services, nil
+}
+
+// ListTasks returns the tasks of the given services.
+func (c *Client) ListTasks(ctx context.Context, services []*Service) ([]*Task, error) {
+	if len(services) == 0 {
+		return []*Task{}, nil
+	}
+	args := []string{"service", "ps", "--quiet", "--no-trunc"}
+	for _, s := range services {
+		args = append(args, s.ID)
+	}
+	stdout, stderr, err := c.Output(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	logWarnings(stderr)
+	args = []string{"inspect", "--type", "task"}
+	for _, id := range ReadLines(stdout) {
+		if id = strings.TrimSpace(id); id != "" {
+			args = append(args, id)
+		}
+	}
+	tasks := []*Task{}
+	if len(args) == 3 {
+		return tasks, nil
+	}
+	var inspected []*taskInspect
+	if err = c.inspectJSON(ctx, &inspected, args...); err != nil {
+		return nil, err
+	}
+	for _, i := range inspected {
+		tasks = append(tasks, &Task{
+			ID:           i.ID,
+			ServiceID:    i.ServiceID,
+			NodeID:       i.NodeID,
+			Slot:         i.Slot,
+			Image:        i.Spec.ContainerSpec.Image,
+			Created:      i.CreatedAt.Unix(),
+			DesiredState: i.DesiredState,
+			State:        i.Status.State,
+			Error:        i.Status.Err,
+		})
+	}
+	return tasks, nil
+}
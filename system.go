@@ -0,0 +1,101 @@
+package fester
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// System contains info about the Docker daemon and the host it runs on.
+type System struct {
+	ServerVersion   string `json:"ServerVersion" yaml:"ServerVersion"`
+	StorageDriver   string `json:"StorageDriver" yaml:"StorageDriver"`
+	OperatingSystem string `json:"OperatingSystem" yaml:"OperatingSystem"`
+	KernelVersion   string `json:"KernelVersion" yaml:"KernelVersion"`
+	Architecture    string `json:"Architecture" yaml:"Architecture"`
+	NCPU            int    `json:"NCPU" yaml:"NCPU"`
+	MemTotal        int64  `json:"MemTotal" yaml:"MemTotal"`
+	// ContainerdSnapshotter is set if the daemon uses the containerd image
+	// store rather than the classic one.
+	ContainerdSnapshotter bool `json:"ContainerdSnapshotter,omitempty" yaml:"ContainerdSnapshotter,omitempty"`
+	// RegistryConfig is where the daemon pulls images from. Older daemons
+	// don't report it, in which case it's left out.
+	RegistryConfig *RegistryConfig `json:"RegistryConfig,omitempty" yaml:"RegistryConfig,omitempty"`
+}
+
+// RegistryConfig is the daemon's registry configuration: the registries it
+// connects to without TLS verification, the mirrors it pulls Docker Hub
+// images through, and the settings for each registry it knows of, keyed by
+// name.
+type RegistryConfig struct {
+	InsecureRegistryCIDRs []string                `json:"InsecureRegistryCIDRs" yaml:"InsecureRegistryCIDRs"`
+	Mirrors               []string                `json:"Mirrors" yaml:"Mirrors"`
+	IndexConfigs          map[string]*IndexConfig `json:"IndexConfigs" yaml:"IndexConfigs"`
+}
+
+// IndexConfig is the daemon's configuration for one registry. Secure is false
+// for insecure registries, and Official is set for Docker Hub.
+type IndexConfig struct {
+	Name     string   `json:"Name" yaml:"Name"`
+	Mirrors  []string `json:"Mirrors" yaml:"Mirrors"`
+	Secure   bool     `json:"Secure" yaml:"Secure"`
+	Official bool     `json:"Official" yaml:"Official"`
+}
+
+// systemInfo is the subset of docker system info output used to build a
+// System.
+type systemInfo struct {
+	ServerVersion   string
+	Driver          string
+	OperatingSystem string
+	KernelVersion   string
+	Architecture    string
+	NCPU            int
+	MemTotal        int64
+	DriverStatus    [][2]string
+	RegistryConfig  *RegistryConfig
+}
+
+// SystemInfo returns info about the Docker daemon and its host.
+func (c *Client) SystemInfo(ctx context.Context) (*System, error) {
+	stdout, stderr, err := c.Output(ctx, "system", "info", "--format", "{{json .}}")
+	if err != nil {
+		return nil, err
+	}
+	logWarnings(stderr)
+	var info systemInfo
+	if err = json.Unmarshal(stdout, &info); err != nil {
+		return nil, err
+	}
+	s := &System{
+		ServerVersion:   info.ServerVersion,
+		StorageDriver:   info.Driver,
+		OperatingSystem: info.OperatingSystem,
+		KernelVersion:   info.KernelVersion,
+		Architecture:    info.Architecture,
+		NCPU:            info.NCPU,
+		MemTotal:        info.MemTotal,
+		RegistryConfig:  info.RegistryConfig,
+	}
+	for _, kv := range info.DriverStatus {
+		if kv[0] == "driver-type" && kv[1] == ContainerdSnapshotter {
+			s.ContainerdSnapshotter = true
+		}
+	}
+	if rc := s.RegistryConfig; rc != nil {
+		if rc.InsecureRegistryCIDRs == nil {
+			rc.InsecureRegistryCIDRs = []string{}
+		}
+		if rc.Mirrors == nil {
+			rc.Mirrors = []string{}
+		}
+		if rc.IndexConfigs == nil {
+			rc.IndexConfigs = map[string]*IndexConfig{}
+		}
+		for _, i := range rc.IndexConfigs {
+			if i.Mirrors == nil {
+				i.Mirrors = []string{}
+			}
+		}
+	}
+	return s, nil
+}
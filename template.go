@@ -0,0 +1,63 @@
+package fester
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs are the functions available to manifest templates in addition
+// to the text/template builtins.
+var TemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// templateLine finds the line number in a text/template error message.
+var templateLine = regexp.MustCompile(`^template: [^:]*:(\d+)`)
+
+// withLine adds the line of text that err refers to, if it can be found, so
+// that template errors are easier to track down.
+func withLine(err error, text string) error {
+	m := templateLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	n, _ := strconv.Atoi(m[1])
+	lines := strings.Split(text, "\n")
+	if n < 1 || n > len(lines) {
+		return err
+	}
+	return fmt.Errorf("%s\n  line %d: %s", err, n, lines[n-1])
+}
+
+// Template is a text/template that can be executed against an OutputMap.
+type Template struct {
+	text string
+	tmpl *template.Template
+}
+
+// ParseTemplate parses text as a text/template with TemplateFuncs available.
+func ParseTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("manifest").Funcs(TemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, withLine(err, text)
+	}
+	return &Template{text: text, tmpl: tmpl}, nil
+}
+
+// Execute runs the template against the OutputMap and returns the result.
+func (o *OutputMap) Execute(t *Template) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, o); err != nil {
+		return nil, withLine(err, t.text)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,42 @@
+package fester
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Thresholds are limits on the counts in a manifest. A negative limit isn't
+// checked.
+type Thresholds struct {
+	MaxExited  int
+	MinRunning int
+	MaxImages  int
+}
+
+// Check returns an error naming each of the thresholds the manifest violates,
+// or nil if it violates none of them.
+func (t Thresholds) Check(o *OutputMap) error {
+	var exited, running int
+	for _, c := range o.AllContainers() {
+		switch c.State {
+		case "exited":
+			exited++
+		case "running":
+			running++
+		}
+	}
+	var failed []string
+	if t.MaxExited >= 0 && exited > t.MaxExited {
+		failed = append(failed, fmt.Sprintf("%d exited containers is more than the maximum of %d", exited, t.MaxExited))
+	}
+	if t.MinRunning >= 0 && running < t.MinRunning {
+		failed = append(failed, fmt.Sprintf("%d running containers is fewer than the minimum of %d", running, t.MinRunning))
+	}
+	if t.MaxImages >= 0 && len(o.Images) > t.MaxImages {
+		failed = append(failed, fmt.Sprintf("%d images is more than the maximum of %d", len(o.Images), t.MaxImages))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("thresholds failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
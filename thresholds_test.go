@@ -0,0 +1,74 @@
+package fester
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThresholdsCheck(t *testing.T) {
+	// o has 2 exited and 3 running containers, one of them in a Compose
+	// project, and 3 images.
+	o := &OutputMap{
+		Images: testImages(),
+		Containers: []*Container{
+			{ID: "c1", State: "running"},
+			{ID: "c2", State: "running"},
+			{ID: "c3", State: "exited"},
+			{ID: "c4", State: "exited"},
+			{ID: "c5", State: "paused"},
+		},
+		Projects: map[string][]*Container{"web": {{ID: "c6", State: "running"}}},
+	}
+	none := Thresholds{MaxExited: -1, MinRunning: -1, MaxImages: -1}
+	tests := []struct {
+		name   string
+		t      Thresholds
+		failed []string
+	}{
+		{name: "none set", t: none},
+		{name: "zero running and images are checked", t: Thresholds{MaxExited: 2, MinRunning: 0, MaxImages: 3}},
+		{name: "exited at the maximum", t: Thresholds{MaxExited: 2, MinRunning: -1, MaxImages: -1}},
+		{name: "exited over the maximum", t: Thresholds{MaxExited: 1, MinRunning: -1, MaxImages: -1}, failed: []string{"2 exited containers is more than the maximum of 1"}},
+		{name: "no exited containers allowed", t: Thresholds{MaxExited: 0, MinRunning: -1, MaxImages: -1}, failed: []string{"2 exited containers is more than the maximum of 0"}},
+		{name: "running at the minimum", t: Thresholds{MaxExited: -1, MinRunning: 3, MaxImages: -1}},
+		{name: "running under the minimum", t: Thresholds{MaxExited: -1, MinRunning: 4, MaxImages: -1}, failed: []string{"3 running containers is fewer than the minimum of 4"}},
+		{name: "images at the maximum", t: Thresholds{MaxExited: -1, MinRunning: -1, MaxImages: 3}},
+		{name: "images over the maximum", t: Thresholds{MaxExited: -1, MinRunning: -1, MaxImages: 2}, failed: []string{"3 images is more than the maximum of 2"}},
+		{
+			name: "every threshold fails",
+			t:    Thresholds{MaxExited: 1, MinRunning: 4, MaxImages: 2},
+			failed: []string{
+				"2 exited containers is more than the maximum of 1",
+				"3 running containers is fewer than the minimum of 4",
+				"3 images is more than the maximum of 2",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.t.Check(o)
+			if len(tt.failed) == 0 {
+				if err != nil {
+					t.Errorf("Check: %s, want no error", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Check succeeded, want an error")
+			}
+			if want := "thresholds failed: " + strings.Join(tt.failed, "; "); err.Error() != want {
+				t.Errorf("Check = %q, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestThresholdsCheckEmptyHost(t *testing.T) {
+	o := &OutputMap{}
+	if err := (Thresholds{MaxExited: 0, MinRunning: 0, MaxImages: 0}).Check(o); err != nil {
+		t.Errorf("Check of an empty host: %s", err)
+	}
+	if err := (Thresholds{MaxExited: -1, MinRunning: 1, MaxImages: -1}).Check(o); err == nil {
+		t.Error("Check of an empty host with a minimum of 1 running succeeded")
+	}
+}
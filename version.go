@@ -0,0 +1,11 @@
+package fester
+
+// Version, GitCommit, and BuildDate describe the fester build. They are meant
+// to be set at build time, for example:
+//
+//	go build -ldflags "-X github.com/johnworth/fester.Version=1.2.0 -X github.com/johnworth/fester.GitCommit=$(git rev-parse HEAD) -X github.com/johnworth/fester.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/fester
+var (
+	Version   = "dev"
+	GitCommit = ""
+	BuildDate = ""
+)
@@ -0,0 +1,24 @@
+package fester
+
+import "context"
+
+// Volume contains the info reported by docker about a volume.
+type Volume struct {
+	Name       string            `json:"Name" yaml:"Name"`
+	Driver     string            `json:"Driver" yaml:"Driver"`
+	Mountpoint string            `json:"Mountpoint" yaml:"Mountpoint"`
+	CreatedAt  string            `json:"CreatedAt,omitempty" yaml:"CreatedAt,omitempty"`
+	Labels     map[string]string `json:"Labels" yaml:"Labels"`
+	Scope      string            `json:"Scope" yaml:"Scope"`
+	Options    map[string]string `json:"Options" yaml:"Options"`
+	SourceURI  string            `json:"source_uri,omitempty" yaml:"source_uri,omitempty"`
+}
+
+// ListVolumes returns the volumes known to the Docker daemon.
+func (c *Client) ListVolumes(ctx context.Context) ([]*Volume, error) {
+	volumes := []*Volume{}
+	if err := c.inspect(ctx, "volume", &volumes); err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}